@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// User labels for the events, keyed by the hex code
+var eventAliases = make(map[string]string)
+
+// Reads a JSON object mapping a built-in event name or a hex code to the label
+// to output instead, e.g. {"47": "vod_category", "`K`Key Press": "keypress"}
+func loadAliases(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var aliases map[string]string
+	if err = json.Unmarshal(data, &aliases); err != nil {
+		return fmt.Errorf("Wrong aliases file %s: %v", fileName, err)
+	}
+
+	for from, label := range aliases {
+		code, ok := findCode(from)
+		if !ok {
+			return fmt.Errorf("Alias for an unknown code or event name in %s: [%s]", fileName, from)
+		}
+		if label == "" {
+			return fmt.Errorf("Empty alias for [%s] in %s", from, fileName)
+		}
+		if previous, ok := eventAliases[code]; ok && previous != label {
+			return fmt.Errorf("Conflicting aliases for code %s in %s: [%s] and [%s]", code, fileName, previous, label)
+		}
+		eventAliases[code] = label
+	}
+	return nil
+}
+
+// Hex code by the code itself or by the built-in event name
+func findCode(codeOrName string) (string, bool) {
+	for _, cmd := range commandsList {
+		if strings.EqualFold(cmd.cmd, codeOrName) || cmd.name == codeOrName {
+			return cmd.cmd, true
+		}
+	}
+	return "", false
+}
+
+// Name the event is reported under, the alias if there is one
+func eventLabel(cmd Command) string {
+	if label, ok := eventAliases[cmd.cmd]; ok {
+		return label
+	}
+	return cmd.name
+}
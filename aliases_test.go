@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAliases(t *testing.T, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "aliases.json")
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestLoadAliases(t *testing.T) {
+	defer func() {
+		eventAliases = make(map[string]string)
+		initEventNames()
+	}()
+
+	fileName := writeAliases(t, `{"47": "vod_category", "`+"`K`Key Press"+`": "keypress"}`)
+	if err := loadAliases(fileName); err != nil {
+		t.Fatal(err)
+	}
+	initEventNames()
+	tests := []struct {
+		clickString string
+		want        string
+	}{
+		{"4700000000", "vod_category"},
+		{"4B00000000", "keypress"},
+		{"5000000000", "`P`Pulse"},
+	}
+	for _, test := range tests {
+		if got, err := convertToLogName(test.clickString, false); err != nil || got != test.want {
+			t.Errorf("convertToLogName(%s) = %q, %v, want %q", test.clickString, got, err, test.want)
+		}
+	}
+}
+
+func TestLoadAliasesErrors(t *testing.T) {
+	defer func() { eventAliases = make(map[string]string) }()
+	for _, content := range []string{
+		`{"ZZ": "nothing"}`,
+		`{"No Such Event": "nothing"}`,
+		`{"47": ""}`,
+		`{"47": "vod", "` + "`G`VOD Category" + `": "category"}`,
+		`["47"]`,
+	} {
+		eventAliases = make(map[string]string)
+		if err := loadAliases(writeAliases(t, content)); err == nil {
+			t.Errorf("loadAliases(%s), want an error", content)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// Hex digits of the HMAC kept as the anonymized device Id
+	anonymizedIdLength = 16
+	idMapFileName      = "idmap.csv"
+)
+
+// Salt of the -anonymize HMAC: the -salt, the one kept in the -salt-file, or a random
+// one. A missing salt file gets a new random salt, so the next runs map the same.
+func anonymizeKey(salt, saltFileName string) ([]byte, error) {
+	if salt != "" {
+		return []byte(salt), nil
+	}
+	if saltFileName != "" {
+		data, err := os.ReadFile(saltFileName)
+		if err == nil {
+			if salt = strings.TrimSpace(string(data)); salt == "" {
+				return nil, fmt.Errorf("Empty salt file %s", saltFileName)
+			}
+			return []byte(salt), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	salt = hex.EncodeToString(random)
+	if saltFileName != "" {
+		if err := os.WriteFile(saltFileName, []byte(salt+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		logInfo("New salt written to %s", saltFileName)
+	}
+	return []byte(salt), nil
+}
+
+// Stable salted hash of the device Id with -anonymize, the Id as it is otherwise
+func (cfg *Config) anonymize(deviceId string) string {
+	if cfg.anonymizeKey == nil {
+		return deviceId
+	}
+	if id, ok := cfg.anonymizedIds.Load(deviceId); ok {
+		return id.(string)
+	}
+	mac := hmac.New(sha256.New, cfg.anonymizeKey)
+	mac.Write([]byte(deviceId))
+	id := hex.EncodeToString(mac.Sum(nil))[:anonymizedIdLength]
+	cfg.anonymizedIds.Store(deviceId, id)
+	return id
+}
+
+// The original to anonymized Ids lookup of -idmap, sorted by the original Id.
+// Written readable by the owner only, it undoes the anonymization.
+func printIdMap(cfg *Config) error {
+	var originals []string
+	cfg.anonymizedIds.Range(func(deviceId, _ any) bool {
+		originals = append(originals, deviceId.(string))
+		return true
+	})
+	sort.Strings(originals)
+
+	path := filepath.Join(outputDir, idMapFileName)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, "deviceId, anonymizedId")
+	for _, deviceId := range originals {
+		id, _ := cfg.anonymizedIds.Load(deviceId)
+		fmt.Fprintf(w, "%s, %s\n", deviceId, id)
+	}
+	if err = w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	logWarn("%s maps the anonymized device Ids back to the real ones, keep it private and out of the shared outputs", path)
+	return nil
+}
+
+// Input line with the device Id anonymized, for the error logs
+func (cfg *Config) anonymizeLine(line string, columns *CsvColumns) string {
+	if cfg.anonymizeKey == nil {
+		return line
+	}
+	if columns != nil {
+		fields := splitCsvLine(line)
+		if columns.deviceId < len(fields) {
+			fields[columns.deviceId] = cfg.anonymize(fields[columns.deviceId])
+		}
+		return strings.Join(fields, ", ")
+	}
+	// The token before the clickstring, as lineDeviceId finds it
+	end := strings.LastIndex(line, " ")
+	if end < 0 {
+		return line
+	}
+	start := strings.LastIndex(line[:end], " ") + 1
+	return line[:start] + cfg.anonymize(line[start:end]) + line[end:]
+}
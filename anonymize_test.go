@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func anonymizingConfig(salt string) *Config {
+	cfg := testConfig()
+	cfg.anonymizeKey = []byte(salt)
+	return cfg
+}
+
+func TestAnonymizeSalts(t *testing.T) {
+	first, second := anonymizingConfig("salt one"), anonymizingConfig("salt two")
+	id := first.anonymize("dev1")
+	if id == "dev1" || len(id) != anonymizedIdLength {
+		t.Fatalf("anonymize(dev1) = %q", id)
+	}
+	if again := first.anonymize("dev1"); again != id {
+		t.Errorf("same salt: %q, then %q", id, again)
+	}
+	if other := second.anonymize("dev1"); other == id {
+		t.Errorf("two salts give the same Id %q", id)
+	}
+	if same := anonymizingConfig("salt one").anonymize("dev1"); same != id {
+		t.Errorf("same salt in another config: %q, want %q", same, id)
+	}
+	if plain := testConfig().anonymize("dev1"); plain != "dev1" {
+		t.Errorf("without -anonymize: %q", plain)
+	}
+}
+
+func TestAnonymizeLine(t *testing.T) {
+	cfg := anonymizingConfig("salt")
+	id := cfg.anonymize("dev1")
+	tests := []struct {
+		line    string
+		columns string
+		want    string
+	}{
+		{"dev1 4100000000", "", id + " 4100000000"},
+		{"2016-03-01 dev1 4100000000", "", "2016-03-01 " + id + " 4100000000"},
+		{"garbage", "", "garbage"},
+		{"2016-03-01T00:00:00Z, dev1, 41", "timestamp, deviceId, eventCode", "2016-03-01T00:00:00Z, " + id + ", 41"},
+	}
+	for _, test := range tests {
+		var columns *CsvColumns
+		if test.columns != "" {
+			var err error
+			if columns, err = parseCsvHeader(test.columns); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := cfg.anonymizeLine(test.line, columns); got != test.want {
+			t.Errorf("anonymizeLine(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+// The failing lines of -errors-only and -validate keep no original Id
+func TestAnonymizeErrorLines(t *testing.T) {
+	cfg := anonymizingConfig("salt")
+	dir := t.TempDir()
+	old := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []string{writeInput(t, dir, "a_MSO1.raw",
+		rawLine(t, "secretdev", "41", old, ""),
+		"secretdev ZZ00000000",
+	)}
+
+	errors := collectErrorLines(cfg, files, time.Now())
+	if len(errors) != 2 {
+		t.Fatalf("%d error lines, want 2", len(errors))
+	}
+	for _, entry := range errors {
+		if strings.Contains(entry.line, "secretdev") {
+			t.Errorf("-errors-only line keeps the Id: %s", entry.line)
+		}
+	}
+
+	var report bytes.Buffer
+	if _, err := validateFiles(cfg, files, 1, time.Now(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(report.String(), "secretdev") {
+		t.Errorf("-validate samples keep the Id:\n%s", report.String())
+	}
+}
+
+// The anonymized Ids of the events log back to the original ones through idmap.csv
+func TestIdMapRoundTrip(t *testing.T) {
+	dir := withOutputDir(t)
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	originals := []string{"0000000003", "0000000001", "00000000000000A2"}
+	var lines []string
+	for i, deviceId := range originals {
+		lines = append(lines, rawLine(t, deviceId, "50", start.Add(time.Duration(i)*time.Second), ""))
+	}
+	file := writeInput(t, t.TempDir(), "idmap_MSO1.raw", lines...)
+
+	cfg := anonymizingConfig("salt")
+	cfg.eventSequenceLog = true
+	eventLogChan := make(chan EventLogEntry, len(originals))
+	if _, err := Process(context.Background(), cfg, []string{file}, eventLogChan, newBufferState(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	close(eventLogChan)
+	if err := printIdMap(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, idMapFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("%s mode %v, want owner only", idMapFileName, perm)
+	}
+	rows := strings.Split(strings.TrimSpace(readOutput(t, dir, idMapFileName)), "\n")
+	if rows[0] != "deviceId, anonymizedId" || len(rows) != len(originals)+1 {
+		t.Fatalf("%s:\n%s", idMapFileName, strings.Join(rows, "\n"))
+	}
+	byAnonymized := make(map[string]string)
+	for _, row := range rows[1:] {
+		deviceId, id, _ := strings.Cut(row, ", ")
+		byAnonymized[id] = deviceId
+	}
+
+	var restored []string
+	for entry := range eventLogChan {
+		restored = append(restored, byAnonymized[entry.deviceId])
+	}
+	sort.Strings(restored)
+	want := append([]string{}, originals...)
+	sort.Strings(want)
+	if !reflect.DeepEqual(restored, want) {
+		t.Errorf("restored Ids %v, want %v", restored, want)
+	}
+}
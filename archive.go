@@ -0,0 +1,437 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	zipExt   = ".zip"
+	tarExt   = ".tar"
+	tarGzExt = ".tar.gz"
+	tgzExt   = ".tgz"
+	// Between the archive path and the entry name in the input paths, e.g. data/delivery.zip!clicks_MSO1.raw
+	archiveSeparator = "!"
+)
+
+var archiveExts = []string{zipExt, tarExt, tarGzExt, tgzExt}
+
+// An entry found listing an archive
+type archiveEntry struct {
+	info os.FileInfo
+	// Position in the archive, the stream order of a tar
+	index int
+}
+
+var (
+	// The entries of the archives listed so far by the input path,
+	// an entry stat does not read the archive again
+	listedEntries   = make(map[string]archiveEntry)
+	listedEntriesMu sync.Mutex
+	// The tars of the run by the archive path, each read once through its entries
+	tarStreams   = make(map[string]*tarStream)
+	tarStreamsMu sync.Mutex
+)
+
+// Extension of the archive, lower case, empty for a plain file
+func archiveExt(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+func isArchive(path string) bool {
+	return archiveExt(path) != ""
+}
+
+// Archive and the entry name of an archive entry input path, ok is false for a plain file
+func splitArchivePath(path string) (archive, entry string, ok bool) {
+	lower := strings.ToLower(path)
+	end := -1
+	for _, ext := range archiveExts {
+		if i := strings.Index(lower, ext+archiveSeparator); i >= 0 && (end < 0 || i+len(ext) < end) {
+			end = i + len(ext)
+		}
+	}
+	if end < 0 {
+		return "", "", false
+	}
+	return path[:end], path[end+len(archiveSeparator):], true
+}
+
+// File name of the input, of the entry for the archive ones, the MSO is taken from it
+func inputBaseName(path string) string {
+	if _, entry, ok := splitArchivePath(path); ok {
+		path = entry
+	}
+	return filepath.Base(path)
+}
+
+// Input paths of the -x entries of the archive. The infos, when not nil, get the entry ones.
+func archiveEntries(archive string, infos map[string]os.FileInfo) ([]string, error) {
+	if archiveExt(archive) != zipExt {
+		return tarEntries(archive, infos)
+	}
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	entries := []string{}
+	for i, file := range reader.File {
+		if file.FileInfo().IsDir() || !isRawFile(file.Name) {
+			continue
+		}
+		path := archive + archiveSeparator + file.Name
+		entries = append(entries, path)
+		addListedEntry(path, archiveEntry{file.FileInfo(), i}, infos)
+	}
+	return entries, nil
+}
+
+func addListedEntry(path string, entry archiveEntry, infos map[string]os.FileInfo) {
+	listedEntriesMu.Lock()
+	listedEntries[path] = entry
+	listedEntriesMu.Unlock()
+	if infos != nil {
+		infos[path] = entry.info
+	}
+}
+
+// The entry as listed, the archive is listed when it was not yet
+func listedEntry(path, archive string) (archiveEntry, bool) {
+	listedEntriesMu.Lock()
+	entry, ok := listedEntries[path]
+	listedEntriesMu.Unlock()
+	if ok {
+		return entry, true
+	}
+	if _, err := archiveEntries(archive, nil); err != nil {
+		return archiveEntry{}, false
+	}
+	listedEntriesMu.Lock()
+	defer listedEntriesMu.Unlock()
+	entry, ok = listedEntries[path]
+	return entry, ok
+}
+
+// A plain input path as it is, an archive as its entries in the -order.
+// The run reads the tar entries in the stream order anyway, see streamTarEntries.
+func expandArchive(path string) []string {
+	if !isArchive(path) {
+		return []string{path}
+	}
+	infos := make(map[string]os.FileInfo)
+	entries, err := archiveEntries(path, infos)
+	if err != nil {
+		logWarn("Error reading archive %s: %v", path, err)
+		return nil
+	}
+	sortFiles(entries, infos)
+	return entries
+}
+
+func findArchiveEntry(reader *zip.ReadCloser, archive, entry string) (*zip.File, error) {
+	for _, file := range reader.File {
+		if file.Name == entry {
+			return file, nil
+		}
+	}
+	return nil, fmt.Errorf("open %s: no entry %s in the archive", archive, entry)
+}
+
+// Entry reader closing the archive with it
+type archiveEntryReader struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+func (reader archiveEntryReader) Close() error {
+	err := reader.ReadCloser.Close()
+	if archiveErr := reader.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
+
+// Opens a plain input file or an archive entry. Every zip entry opens the archive again,
+// the zip central directory is read once per entry. A tar has no directory: the entries
+// of the run take turns on its stream, the others stream it from the start up to the entry.
+func openInput(path string) (io.ReadCloser, error) {
+	archive, entry, ok := splitArchivePath(path)
+	if !ok {
+		return os.Open(path)
+	}
+	if archiveExt(archive) != zipExt {
+		tarStreamsMu.Lock()
+		stream, ok := tarStreams[archive]
+		tarStreamsMu.Unlock()
+		if ok {
+			if reader, ok, err := stream.open(path, entry); ok {
+				return reader, err
+			}
+		}
+		reader, err := openTar(archive)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = reader.find(entry); err != nil {
+			reader.Close()
+			return nil, err
+		}
+		return archiveEntryReader{io.NopCloser(reader), reader}, nil
+	}
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	file, err := findArchiveEntry(reader, archive, entry)
+	if err == nil {
+		var content io.ReadCloser
+		if content, err = file.Open(); err == nil {
+			return archiveEntryReader{content, reader}, nil
+		}
+	}
+	reader.Close()
+	return nil, err
+}
+
+// os.Stat of the inputs, the archive entries included. An archive is listed once
+// for all its entries.
+func statInput(path string) (os.FileInfo, error) {
+	archive, entry, ok := splitArchivePath(path)
+	if !ok {
+		return os.Stat(path)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		return nil, err
+	}
+	listed, ok := listedEntry(path, archive)
+	if !ok {
+		return nil, fmt.Errorf("stat %s: no entry %s in the archive", archive, entry)
+	}
+	return listed.info, nil
+}
+
+// Tar stream of an archive file, gunzipped for the .tar.gz and .tgz ones
+type tarArchive struct {
+	*tar.Reader
+	name string
+	file *os.File
+	gz   *gzip.Reader
+}
+
+func openTar(archive string) (*tarArchive, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	reader := &tarArchive{name: archive, file: file}
+	if ext := archiveExt(archive); ext == tarGzExt || ext == tgzExt {
+		if reader.gz, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %v", archive, err)
+		}
+		reader.Reader = tar.NewReader(reader.gz)
+	} else {
+		reader.Reader = tar.NewReader(file)
+	}
+	return reader, nil
+}
+
+// Reads on to the entry, its content is read next
+func (reader *tarArchive) find(entry string) (*tar.Header, error) {
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("open %s: no entry %s in the archive", reader.name, entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", reader.name, err)
+		}
+		if header.Name == entry && header.Typeflag == tar.TypeReg {
+			return header, nil
+		}
+	}
+}
+
+func (reader *tarArchive) Close() error {
+	if reader.gz != nil {
+		reader.gz.Close()
+	}
+	return reader.file.Close()
+}
+
+// The regular file -x entries of a tar, as archiveEntries
+func tarEntries(archive string, infos map[string]os.FileInfo) ([]string, error) {
+	reader, err := openTar(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	entries := []string{}
+	index := -1
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", archive, err)
+		}
+		index++
+		if header.Typeflag != tar.TypeReg || !isRawFile(header.Name) {
+			continue
+		}
+		path := archive + archiveSeparator + header.Name
+		entries = append(entries, path)
+		addListedEntry(path, archiveEntry{header.FileInfo(), index}, infos)
+	}
+}
+
+// A tar read once from the start through the entries of the run, in the stream order.
+// An entry is opened once the one before it is closed, the workers reading the same
+// tar take turns on it.
+type tarStream struct {
+	sync.Mutex
+	turn    *sync.Cond
+	archive *tarArchive
+	// The turns of the run entries by the input path, and the next turn
+	ranks map[string]int
+	next  int
+	busy  bool
+}
+
+// Puts the entries of every tar in the list into the stream order, within the places
+// the -order gave them, and sets up the streams the run reads them through
+func streamTarEntries(fileList []string) {
+	places := make(map[string][]int)
+	for i, path := range fileList {
+		if archive, _, ok := splitArchivePath(path); ok && archiveExt(archive) != zipExt {
+			places[archive] = append(places[archive], i)
+		}
+	}
+
+	listedEntriesMu.Lock()
+	defer listedEntriesMu.Unlock()
+	tarStreamsMu.Lock()
+	defer tarStreamsMu.Unlock()
+	for archive, positions := range places {
+		entries := make([]string, len(positions))
+		for i, position := range positions {
+			entries[i] = fileList[position]
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return listedEntries[entries[i]].index < listedEntries[entries[j]].index
+		})
+		stream := &tarStream{ranks: make(map[string]int, len(entries))}
+		stream.turn = sync.NewCond(stream)
+		for i, entry := range entries {
+			fileList[positions[i]] = entry
+			if _, ok := stream.ranks[entry]; !ok {
+				stream.ranks[entry] = len(stream.ranks)
+			}
+		}
+		if old, ok := tarStreams[archive]; ok {
+			old.closeArchive()
+		}
+		tarStreams[archive] = stream
+	}
+}
+
+// Waits for the turn of the entry and reads the stream on to it. ok is false for the
+// entries with no turn, not in the run list or already taken once.
+func (stream *tarStream) open(path, entry string) (io.ReadCloser, bool, error) {
+	stream.Lock()
+	defer stream.Unlock()
+	rank, ok := stream.ranks[path]
+	if !ok {
+		return nil, false, nil
+	}
+	for stream.busy || stream.next < rank {
+		stream.turn.Wait()
+	}
+	if stream.next > rank {
+		return nil, false, nil
+	}
+
+	stream.busy = true
+	err := stream.find(path, entry)
+	if err != nil {
+		stream.closeArchive()
+		stream.done(rank)
+		return nil, true, err
+	}
+	return &tarStreamEntry{stream.archive, stream, rank}, true, nil
+}
+
+// Reads on to the entry, from the start again when it is behind the stream
+func (stream *tarStream) find(path, entry string) error {
+	if stream.archive == nil {
+		archive, _, _ := splitArchivePath(path)
+		reader, err := openTar(archive)
+		if err != nil {
+			return err
+		}
+		stream.archive = reader
+	}
+	if _, err := stream.archive.find(entry); err == nil {
+		return nil
+	}
+	// Not on from here, e.g. a missing entry
+	name := stream.archive.name
+	stream.closeArchive()
+	reader, err := openTar(name)
+	if err != nil {
+		return err
+	}
+	stream.archive = reader
+	_, err = reader.find(entry)
+	return err
+}
+
+// Caller holds the lock
+func (stream *tarStream) done(rank int) {
+	stream.busy = false
+	stream.next = rank + 1
+	if stream.next >= len(stream.ranks) {
+		// The last entry of the run
+		stream.closeArchive()
+	}
+	stream.turn.Broadcast()
+}
+
+func (stream *tarStream) closeArchive() {
+	if stream.archive != nil {
+		stream.archive.Close()
+		stream.archive = nil
+	}
+}
+
+// Content of the current entry of a tar stream, closing it hands the stream to the next entry
+type tarStreamEntry struct {
+	io.Reader
+	stream *tarStream
+	rank   int
+}
+
+func (entry *tarStreamEntry) Close() error {
+	entry.stream.Lock()
+	defer entry.stream.Unlock()
+	entry.stream.done(entry.rank)
+	return nil
+}
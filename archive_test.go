@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tar of the name, content entries in the given order
+func writeTar(t *testing.T, path string, entries ...string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := tar.NewWriter(file)
+	for i := 0; i < len(entries); i += 2 {
+		content := entries[i+1]
+		if err = w.WriteHeader(&tar.Header{Name: entries[i], Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+}
+
+func readInput(t *testing.T, path string) string {
+	t.Helper()
+	reader, err := openInput(path)
+	if err != nil {
+		t.Fatalf("openInput(%s): %v", path, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path, archive, entry string
+		ok                   bool
+	}{
+		{"data/a_MSO1.raw", "", "", false},
+		{"data/d.zip!a_MSO1.raw", "data/d.zip", "a_MSO1.raw", true},
+		{"d.tar.gz!dir/a_MSO1.raw", "d.tar.gz", "dir/a_MSO1.raw", true},
+		{"D.TGZ!a_MSO1.raw", "D.TGZ", "a_MSO1.raw", true},
+		{"d.tar!x.zip!a.raw", "d.tar", "x.zip!a.raw", true},
+	}
+	for _, test := range tests {
+		archive, entry, ok := splitArchivePath(test.path)
+		if archive != test.archive || entry != test.entry || ok != test.ok {
+			t.Errorf("splitArchivePath(%s) = %s, %s, %v", test.path, archive, entry, ok)
+		}
+	}
+}
+
+// The entries are read through a single stream of the tar, in its order
+func TestTarStreamReadOnce(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "d.tar")
+	writeTar(t, archive, "c_MSO1.raw", "c\n", "a_MSO1.raw", "a\n", "b_MSO1.raw", "b\n")
+	entries := expandArchive(archive)
+	streamTarEntries(entries)
+	want := []string{archive + "!c_MSO1.raw", archive + "!a_MSO1.raw", archive + "!b_MSO1.raw"}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries %v, want the stream order %v", entries, want)
+	}
+
+	if got := readInput(t, entries[0]); got != "c\n" {
+		t.Errorf("first entry: %q", got)
+	}
+	// Gone from the directory, the open stream reads on
+	if err := os.Remove(archive); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range []string{"a\n", "b\n"} {
+		if got := readInput(t, entries[i+1]); got != content {
+			t.Errorf("entry %d: %q, want %q", i+1, got, content)
+		}
+	}
+}
+
+// The workers opening the entries at once get them in turns
+func TestTarStreamTurns(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "d.tar")
+	var contents []string
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		contents = append(contents, name+"_MSO1.raw", name+"\n")
+	}
+	writeTar(t, archive, contents...)
+	entries := expandArchive(archive)
+	streamTarEntries(entries)
+
+	got := make([]string, len(entries))
+	var wg sync.WaitGroup
+	for i := len(entries) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reader, err := openInput(entries[i])
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := io.ReadAll(reader)
+			reader.Close()
+			got[i] = string(data)
+		}(i)
+	}
+	wg.Wait()
+	for i := range entries {
+		if want := contents[2*i+1]; got[i] != want {
+			t.Errorf("%s: %q, want %q", entries[i], got[i], want)
+		}
+	}
+}
+
+func TestStatInputArchiveEntry(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "d.tar")
+	writeTar(t, archive, "a_MSO1.raw", "abc\n")
+	info, err := statInput(archive + "!a_MSO1.raw")
+	if err != nil || info.Size() != 4 {
+		t.Fatalf("statInput: %v, %v", info, err)
+	}
+	if _, err = statInput(archive + "!missing.raw"); err == nil {
+		t.Error("statInput of a missing entry, no error")
+	}
+}
+
+// Zip built in memory: the -x entries only, read by the entry path, the MSO from the entry name
+func TestZipInput(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	first := rawLine(t, "dev1", "50", start, "") + "\n"
+	second := rawLine(t, "dev2", "50", start.Add(time.Second), "") + "\n" + rawLine(t, "dev2", "50", start.Add(2*time.Second), "") + "\n"
+
+	var buffer bytes.Buffer
+	w := zip.NewWriter(&buffer)
+	for _, entry := range []struct{ name, content string }{
+		{"day1/", ""},
+		{"day1/a_MSO1.raw", first},
+		{"notes.txt", "not an input"},
+		{"day2/b_MSO2.raw", second},
+	} {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, entry.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "delivery.zip")
+	if err := os.WriteFile(archive, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := expandArchive(archive)
+	want := []string{archive + "!day1/a_MSO1.raw", archive + "!day2/b_MSO2.raw"}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries %v, want %v", entries, want)
+	}
+	if mso := msoName(entries[1]); mso != "MSO2" {
+		t.Errorf("msoName(%s) = %s, want MSO2", entries[1], mso)
+	}
+	if got := readInput(t, entries[1]); got != second {
+		t.Errorf("%s:\n%s\nwant:\n%s", entries[1], got, second)
+	}
+
+	_, result := processPackages(t, testConfig(), entries)
+	if result.files != 2 || result.validEvents != 3 || len(result.errors) != 0 {
+		t.Errorf("%d files, %d valid events, errors %v, want 2 files of 3 events", result.files, result.validEvents, result.errors)
+	}
+}
+
+// A constructed tar.gz streamed through the run, the MSO and the source file by the entry
+func TestTarGzInput(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	plain := filepath.Join(dir, "daily.tar")
+	writeTar(t, plain,
+		"2016-03-01/a_MSO1.raw", rawLine(t, "dev1", "50", start, "")+"\n",
+		"README", "not an input",
+		"2016-03-01/b_MSO2.raw", rawLine(t, "dev2", "50", start, "")+"\n"+rawLine(t, "dev2", "50", start.Add(time.Second), "")+"\n")
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "daily.tar.gz")
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := gzip.NewWriter(file)
+	w.Write(data)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	entries := expandArchive(archive)
+	want := []string{archive + "!2016-03-01/a_MSO1.raw", archive + "!2016-03-01/b_MSO2.raw"}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries %v, want %v", entries, want)
+	}
+	streamTarEntries(entries)
+
+	cfg := testConfig()
+	cfg.eventSequenceLog = true
+	eventLogChan := make(chan EventLogEntry, 3)
+	result, err := Process(context.Background(), cfg, entries, eventLogChan, newBufferState(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(eventLogChan)
+	if result.validEvents != 3 || len(result.errors) != 0 {
+		t.Fatalf("%d valid events, errors %v, want 3", result.validEvents, result.errors)
+	}
+	for entry := range eventLogChan {
+		if wantMso := map[string]string{"dev1": "MSO1", "dev2": "MSO2"}[entry.deviceId]; entry.mso != wantMso || !strings.HasPrefix(entry.sourceFile, archive+"!2016-03-01/") {
+			t.Errorf("%s event of %s in %s, want %s", entry.deviceId, entry.mso, entry.sourceFile, wantMso)
+		}
+	}
+}
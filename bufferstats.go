@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const bufferStatsFileName = "bufferStats.csv"
+
+// Buffer utilization of a single device
+type DeviceBufferStats struct {
+	events    int
+	fillSum   int
+	peakFill  int // highest fill right before a package was sent
+	crossings int
+}
+
+// Caller holds the buffers lock
+func (state *BufferState) deviceStats(deviceId string) *DeviceBufferStats {
+	stats, ok := state.stats[deviceId]
+	if !ok {
+		stats = &DeviceBufferStats{}
+		state.stats[deviceId] = stats
+	}
+	return stats
+}
+
+func (stats *DeviceBufferStats) add(fill, fillBefore int, crossed bool) {
+	stats.events++
+	stats.fillSum += fill
+	if crossed {
+		stats.crossings++
+		if fillBefore > stats.peakFill {
+			stats.peakFill = fillBefore
+		}
+	}
+}
+
+func (stats *DeviceBufferStats) averageFill() float64 {
+	if stats.events == 0 {
+		return 0
+	}
+	return float64(stats.fillSum) / float64(stats.events)
+}
+
+// Sorted by the watermark crossings, the hottest devices first
+func printBufferStats(buffers *BufferState) {
+	buffers.Lock()
+	defer buffers.Unlock()
+
+	devices := make([]string, 0, len(buffers.stats))
+	for deviceId := range buffers.stats {
+		devices = append(devices, deviceId)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		left, right := buffers.stats[devices[i]], buffers.stats[devices[j]]
+		if left.crossings != right.crossings {
+			return left.crossings > right.crossings
+		}
+		return devices[i] < devices[j]
+	})
+
+	w, err := createOutputFile(bufferStatsFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "deviceId, events, averageFill, peakFillBeforeSend, crossings")
+	for _, deviceId := range devices {
+		stats := buffers.stats[deviceId]
+		fmt.Fprintf(w, "%s, %d, %.1f, %d, %d\n",
+			deviceId, stats.events, stats.averageFill(), stats.peakFill, stats.crossings)
+	}
+	w.Close()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceBufferStats(t *testing.T) {
+	stats := &DeviceBufferStats{}
+	stats.add(400, 0, false)
+	stats.add(700, 400, false)
+	stats.add(100, 700, true)
+	stats.add(750, 100, false)
+	stats.add(50, 750, true)
+	if stats.events != 5 || stats.crossings != 2 || stats.peakFill != 750 {
+		t.Errorf("events %d, crossings %d, peak %d, want 5, 2, 750", stats.events, stats.crossings, stats.peakFill)
+	}
+	if average := stats.averageFill(); average != 400 {
+		t.Errorf("averageFill = %.1f, want 400", average)
+	}
+	if average := (&DeviceBufferStats{}).averageFill(); average != 0 {
+		t.Errorf("averageFill of no events = %.1f, want 0", average)
+	}
+}
+
+// The crossings are the packages of the device, the file is the same from run to run
+func TestPrintBufferStats(t *testing.T) {
+	dir := withOutputDir(t)
+	files := writeSpreadFiles(t, t.TempDir(), 4, 10, 200)
+	cfg := testConfig()
+	cfg.bufferStats = true
+
+	var outputs []string
+	for run := 0; run < 2; run++ {
+		buffers := newBufferState()
+		result, err := Process(context.Background(), cfg, files, nil, buffers, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		packages := make(map[string]int)
+		for _, pkg := range result.packages {
+			packages[pkg.deviceId]++
+		}
+		for deviceId, stats := range buffers.stats {
+			if stats.crossings != packages[deviceId] {
+				t.Errorf("%s: %d crossings, %d packages", deviceId, stats.crossings, packages[deviceId])
+			}
+		}
+		printBufferStats(buffers)
+		outputs = append(outputs, readOutput(t, dir, bufferStatsFileName))
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("runs differ:\n%s\n%s", outputs[0], outputs[1])
+	}
+
+	lines := strings.Split(strings.TrimSpace(outputs[0]), "\n")
+	if len(lines) != 11 || lines[0] != "deviceId, events, averageFill, peakFillBeforeSend, crossings" {
+		t.Fatalf("%d lines, header %q", len(lines), lines[0])
+	}
+	previous := -1
+	for _, line := range lines[1:] {
+		var deviceId string
+		var events, peak, crossings int
+		var average float64
+		if _, err := fmt.Sscanf(strings.ReplaceAll(line, ",", ""), "%s %d %f %d %d", &deviceId, &events, &average, &peak, &crossings); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if previous >= 0 && crossings > previous {
+			t.Errorf("%q after %d crossings, want the most crossings first", line, previous)
+		}
+		previous = crossings
+	}
+}
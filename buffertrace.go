@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	bufferTraceFileName = "bufferTrace.csv"
+	traceAllDevices     = "all"
+)
+
+// Buffer fill right after the event, crossed means the event did not fit
+// under the watermark, so a package was sent and the buffer started over
+type BufferTraceEntry struct {
+	timestamp time.Time
+	deviceId  string
+	eventCode string
+	eventSize int
+	fill      int
+	crossed   bool
+}
+
+var bufferTrace []BufferTraceEntry
+
+func (cfg *Config) isTracedDevice(deviceId string) bool {
+	return cfg.bufferTraceDevice == traceAllDevices || (cfg.bufferTraceDevice != "" && cfg.bufferTraceDevice == deviceId)
+}
+
+func printBufferTrace() {
+	// Per device trajectory, events of a device keep the processing order
+	sort.SliceStable(bufferTrace, func(i, j int) bool {
+		if bufferTrace[i].deviceId != bufferTrace[j].deviceId {
+			return bufferTrace[i].deviceId < bufferTrace[j].deviceId
+		}
+		return bufferTrace[i].timestamp.Before(bufferTrace[j].timestamp)
+	})
+
+	w, err := createOutputFile(bufferTraceFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "timestamp, deviceId, eventCode, eventSize, bufferFill, watermark, crossed")
+	for _, entry := range bufferTrace {
+		fmt.Fprintf(w, "%v, %s, %s, %d, %d, %d, %t\n",
+			entry.timestamp, entry.deviceId, entry.eventCode, entry.eventSize, entry.fill, BuffWaterMarkSize, entry.crossed)
+	}
+	w.Close()
+}
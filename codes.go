@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Codes config entry, extends or overrides the built-in commandsList.
+// Size, when set, replaces the clickstring length as the event size in the buffer.
+// Payload, when set, lists the fields decoded from the clickstring into the VOD log.
+// TimestampBytes, when set, replaces the -ts-bytes timestamp width for the code.
+type CodeConfig struct {
+	Code           string         `json:"code"`
+	Name           string         `json:"name"`
+	Diagnostic     *bool          `json:"diagnostic"`
+	Size           int            `json:"size"`
+	Payload        []PayloadField `json:"payload"`
+	TimestampBytes int            `json:"timestampBytes"`
+}
+
+// Payload field of a clickstring, e.g. the tuned channel of a Channel Change.
+// Offset and length are in bytes, the code is byte 0 and the timestamp bytes 1-4.
+// A wider timestamp shifts the fields by the extra bytes.
+type PayloadField struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	// number (big endian unsigned), string (ASCII) or hex, the default
+	Type string `json:"type"`
+}
+
+const (
+	payloadNumber = "number"
+	payloadString = "string"
+	payloadHex    = "hex"
+)
+
+// Clickstring timestamp widths in bytes, GPS seconds in the 4 bytes of the R31 format.
+// Wider ones are whole seconds too, the payload starts after them.
+const (
+	defaultTimestampBytes = 4
+	maxTimestampBytes     = 7
+)
+
+var (
+	// Fixed event sizes by the hex code, from the codes config
+	eventSizes = make(map[string]int)
+	// Decoded payload fields by the hex code, from the codes config
+	payloadFields = make(map[string][]PayloadField)
+	// Timestamp widths by the hex code, from the codes config
+	timestampWidths = make(map[string]int)
+)
+
+func loadCodesConfig(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var codes []CodeConfig
+	if err = json.Unmarshal(data, &codes); err != nil {
+		return fmt.Errorf("Wrong codes config %s: %v", fileName, err)
+	}
+
+	for _, code := range codes {
+		code.Code = strings.ToUpper(code.Code)
+		if len(code.Code) != 2 {
+			return fmt.Errorf("Wrong code in %s: [%s], expected a hex byte", fileName, code.Code)
+		}
+		if code.Size < 0 {
+			return fmt.Errorf("Wrong size for code %s in %s: %d", code.Code, fileName, code.Size)
+		}
+		if code.TimestampBytes != 0 && !isTimestampWidth(code.TimestampBytes) {
+			return fmt.Errorf("Wrong timestamp bytes for code %s in %s: %d", code.Code, fileName, code.TimestampBytes)
+		}
+		if code.TimestampBytes != 0 {
+			timestampWidths[code.Code] = code.TimestampBytes
+		}
+		applyCodeConfig(code)
+		if code.Size > 0 {
+			eventSizes[code.Code] = code.Size
+		}
+		if len(code.Payload) > 0 {
+			if err = validatePayload(code.Payload); err != nil {
+				return fmt.Errorf("Wrong payload for code %s in %s: %v", code.Code, fileName, err)
+			}
+			payloadFields[code.Code] = code.Payload
+		}
+	}
+	return nil
+}
+
+func applyCodeConfig(code CodeConfig) {
+	for i := range commandsList {
+		if commandsList[i].cmd == code.Code {
+			if code.Name != "" {
+				commandsList[i].name = code.Name
+			}
+			if code.Diagnostic != nil {
+				commandsList[i].diagnostic = *code.Diagnostic
+			}
+			return
+		}
+	}
+	if code.Name == "" {
+		// Size only entry for an unknown code, nothing to name it by
+		logWarn("Code %s is not known and has no name, ignored", code.Code)
+		return
+	}
+	commandsList = append(commandsList, Command{code.Code, code.Name, code.Diagnostic != nil && *code.Diagnostic})
+}
+
+// Bytes the event takes in the buffer: the fixed size from the codes config,
+// or the decoded clickstring length, plus the per event framing overhead
+func calculateEventSize(clickString string, overhead int) int {
+	size, ok := eventSizes[clickString[0:2]]
+	if !ok {
+		size = len(clickString) / 2
+	}
+	return size + overhead
+}
+
+func isTimestampWidth(width int) bool {
+	return width >= defaultTimestampBytes && width <= maxTimestampBytes
+}
+
+// Bytes of the clickstring timestamp: the width from the codes config, or the -ts-bytes one
+func timestampWidth(clickString string, defaultWidth int) int {
+	if width, ok := timestampWidths[clickString[0:2]]; ok {
+		return width
+	}
+	return defaultWidth
+}
+
+func validatePayload(fields []PayloadField) error {
+	for i := range fields {
+		field := &fields[i]
+		if field.Type == "" {
+			field.Type = payloadHex
+		}
+		switch {
+		case field.Name == "":
+			return fmt.Errorf("field %d has no name", i)
+		case field.Offset < 5:
+			return fmt.Errorf("field %s offset %d overlaps the code and timestamp", field.Name, field.Offset)
+		case field.Length <= 0:
+			return fmt.Errorf("field %s length %d", field.Name, field.Length)
+		case field.Type == payloadNumber && field.Length > 8:
+			return fmt.Errorf("field %s is too long for a number: %d", field.Name, field.Length)
+		case field.Type != payloadNumber && field.Type != payloadString && field.Type != payloadHex:
+			return fmt.Errorf("field %s type %s, expected number, string or hex", field.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// Decodes the payload fields configured for the event code as "name=value" pairs.
+// Fields past the end of a short clickstring are left out. shift is the timestamp
+// bytes over the 4 the field offsets count with.
+func decodePayload(clickString string, shift int) (string, bool) {
+	fields, ok := payloadFields[clickString[0:2]]
+	if !ok {
+		return "", false
+	}
+
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		start := (field.Offset + shift) * 2
+		end := start + field.Length*2
+		if end > len(clickString) {
+			continue
+		}
+		raw, err := hex.DecodeString(clickString[start:end])
+		if err != nil {
+			continue
+		}
+		var value string
+		switch field.Type {
+		case payloadNumber:
+			var number uint64
+			for _, b := range raw {
+				number = number<<8 | uint64(b)
+			}
+			value = strconv.FormatUint(number, 10)
+		case payloadString:
+			value = printableString(decodeText(raw))
+		default:
+			value = strings.ToUpper(clickString[start:end])
+		}
+		values = append(values, field.Name+"="+value)
+	}
+	return strings.Join(values, " "), true
+}
+
+// Callsigns are space or null padded, anything unprintable is shown as '.'
+func printableString(text string) string {
+	printable := []rune(strings.TrimRight(text, "\x00 "))
+	for i, r := range printable {
+		if r < ' ' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			printable[i] = '.'
+		}
+	}
+	return string(printable)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalculateEventSize(t *testing.T) {
+	eventSizes["4D"] = 20
+	defer delete(eventSizes, "4D")
+
+	tests := []struct {
+		clickString string
+		overhead    int
+		want        int
+	}{
+		{"50000000000A0B", 0, 7},
+		{"50000000000A0B", 8, 15},
+		// Fixed size of the codes config, whatever the payload
+		{"4D0000000000", 0, 20},
+		{"4D00000000000102030405", 3, 23},
+	}
+	for _, test := range tests {
+		if got := calculateEventSize(test.clickString, test.overhead); got != test.want {
+			t.Errorf("calculateEventSize(%s, %d) = %d, want %d", test.clickString, test.overhead, got, test.want)
+		}
+	}
+}
+
+// -size-overhead and the codes config sizes in the parsed events
+func TestParseEventSize(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "codes.json")
+	if err := os.WriteFile(config, []byte(`[{"code": "4d", "size": 32}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadCodesConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(eventSizes, "4D")
+
+	cfg := testConfig()
+	cfg.sizeOverhead = 4
+	timestamp := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		code    string
+		payload string
+		want    int
+	}{
+		{"50", "", 5 + 4},
+		{"50", "0A0B0C", 8 + 4},
+		{"4D", "0A0B0C", 32 + 4},
+	}
+	for _, test := range tests {
+		line := rawLine(t, "dev1", test.code, timestamp, test.payload)
+		_, _, _, eventSize, _, err := parseEvent(cfg, line, nil, LineSource{"MSO1", "a_MSO1.raw", 1}, time.Now())
+		if err != nil || eventSize != test.want {
+			t.Errorf("parseEvent(%s) size %d, %v, want %d", line, eventSize, err, test.want)
+		}
+	}
+}
+
+// Channel number and callsign of the Channel Change clickstrings, by the codes config offsets
+func TestDecodePayloadChannelChange(t *testing.T) {
+	fields := []PayloadField{
+		{Name: "channel", Offset: 5, Length: 2, Type: payloadNumber},
+		{Name: "callsign", Offset: 7, Length: 4, Type: payloadString},
+		{Name: "flags", Offset: 11, Length: 1},
+	}
+	if err := validatePayload(fields); err != nil {
+		t.Fatal(err)
+	}
+	payloadFields["43"] = fields
+	defer delete(payloadFields, "43")
+
+	tests := []struct {
+		clickString string
+		shift       int
+		want        string
+		ok          bool
+	}{
+		// Channel 515, WABC
+		{"435A0B1C2D" + "0203" + "57414243" + "0F", 0, "channel=515 callsign=WABC flags=0F", true},
+		// The 5 byte timestamp shifts the fields by one byte
+		{"43005A0B1C2D" + "0203" + "57414243" + "0F", 1, "channel=515 callsign=WABC flags=0F", true},
+		// Too short for the callsign and flags
+		{"435A0B1C2D" + "0007", 0, "channel=7", true},
+		{"635A0B1C2D" + "0203", 0, "", false},
+	}
+	for _, test := range tests {
+		got, ok := decodePayload(test.clickString, test.shift)
+		if got != test.want || ok != test.ok {
+			t.Errorf("decodePayload(%s, %d) = %q, %v, want %q, %v", test.clickString, test.shift, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// The code byte lookup names every commands list code as the eventNames map does,
+// the codes config ones included
+func TestEventNamesByCode(t *testing.T) {
+	saved := append([]Command(nil), commandsList...)
+	defer func() {
+		commandsList = saved
+		initEventNames()
+	}()
+
+	checkNames := func(when string) {
+		t.Helper()
+		for _, cmd := range commandsList {
+			got, err := convertToLogName(cmd.cmd+"56A7B3C0", true)
+			if err != nil || got != eventNames[cmd.cmd] || got != eventLabel(cmd) {
+				t.Errorf("%s: convertToLogName(%s) = %q, %v, want %q", when, cmd.cmd, got, err, eventNames[cmd.cmd])
+			}
+		}
+	}
+	checkNames("built-in")
+
+	config := filepath.Join(t.TempDir(), "codes.json")
+	if err := os.WriteFile(config, []byte(`[{"code": "e1", "name": "Extra"}, {"code": "50", "name": "Heartbeat"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadCodesConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	initEventNames()
+	checkNames("-codes")
+	for clickString, want := range map[string]string{"E156A7B3C0": "Extra", "5056A7B3C0": "Heartbeat"} {
+		if got, err := convertToLogName(clickString, true); err != nil || got != eventNames[clickString[0:2]] || !strings.Contains(got, want) {
+			t.Errorf("-codes: convertToLogName(%s) = %q, %v, want %s", clickString, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type timepointRecord struct {
+	Timestamp time.Time `json:"timestamp" xml:"timestamp,attr"`
+	Events    int       `json:"events" xml:"events,attr"`
+}
+
+type errorRecord struct {
+	FileName string `json:"file" xml:"file,attr"`
+	LineNo   int    `json:"lineNo" xml:"lineNo,attr"`
+	Category string `json:"category" xml:"category,attr"`
+	Error    string `json:"error" xml:"error,attr"`
+	Line     string `json:"line" xml:",chardata"`
+}
+
+type eventRecord struct {
+	Timestamp time.Time `json:"timestamp" xml:"timestamp,attr"`
+	Received  string    `json:"received" xml:"received,attr"`
+	DeviceId  string    `json:"deviceId" xml:"deviceId,attr"`
+	EventCode string    `json:"eventCode" xml:"eventCode,attr"`
+	Mso       string    `json:"mso" xml:"mso,attr"`
+	Size      int       `json:"size,omitempty" xml:"size,attr,omitempty"`
+	Raw       string    `json:"raw,omitempty" xml:"raw,attr,omitempty"`
+	Source    string    `json:"sourceFile,omitempty" xml:"sourceFile,attr,omitempty"`
+	LineNo    int       `json:"lineNo,omitempty" xml:"lineNo,attr,omitempty"`
+}
+
+// All the outputs of a run as a single document
+type combinedRecord struct {
+	XMLName         xml.Name          `json:"-" xml:"run"`
+	Packages        []packageRecord   `json:"packages" xml:"packages>package"`
+	EventsPerSecond []timepointRecord `json:"eventsPerSecond" xml:"eventsPerSecond>second"`
+	Errors          []errorRecord     `json:"errors" xml:"errors>error"`
+	Vod             []eventRecord     `json:"vod" xml:"vod>event"`
+}
+
+func newCombinedRecord(packages PackageList, eventsPerSecond TimepointTypeList, eventsLog OrderedVodLogList, errors []ErrorLogEntry) combinedRecord {
+	record := combinedRecord{
+		Packages:        make([]packageRecord, 0, len(packages)),
+		EventsPerSecond: make([]timepointRecord, 0, len(eventsPerSecond)),
+		Errors:          make([]errorRecord, 0, len(errors)),
+		Vod:             make([]eventRecord, 0, len(eventsLog)),
+	}
+	for _, pkg := range packages {
+		record.Packages = append(record.Packages, newPackageRecord(pkg))
+	}
+	for _, points := range eventsPerSecond {
+		record.EventsPerSecond = append(record.EventsPerSecond, timepointRecord{points.timestamp, points.numberOfEvents})
+	}
+	for _, entry := range errors {
+		record.Errors = append(record.Errors, errorRecord{entry.fileName, entry.lineNo, entry.category.String(), entry.err.Error(), entry.line})
+	}
+	for _, event := range eventsLog {
+		eventSize, sourceFile, lineNo := 0, "", 0
+		if eventSizeColumn {
+			eventSize = event.size
+		}
+		if traceSource {
+			sourceFile, lineNo = event.sourceFile, event.lineNo
+		}
+		record.Vod = append(record.Vod, eventRecord{event.timestamp, event.received, event.deviceId, event.eventcode, event.mso, eventSize, event.raw, sourceFile, lineNo})
+	}
+	return record
+}
+
+// Plain text formats get a labeled section per output
+func writeCombinedSections(w io.Writer, packages PackageList, eventsPerSecond TimepointTypeList, eventsLog OrderedVodLogList, errors []ErrorLogEntry) error {
+	fmt.Fprintln(w, "[packages]")
+	if err := writePackages(w, packages); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\n[eventsPerSecond]")
+	for _, points := range eventsPerSecond {
+		fmt.Fprintf(w, "%v, %d\n", points.timestamp, points.numberOfEvents)
+	}
+	fmt.Fprintln(w, "\n[errors]")
+	for _, logEntry := range errors {
+		fmt.Fprintf(w, "File: %s \t lineNo: %d\t Error:%s\nEntry:[%s]\n",
+			logEntry.fileName, logEntry.lineNo, logEntry.err, logEntry.line)
+	}
+	fmt.Fprintln(w, "\n[vod]")
+	for _, event := range eventsLog {
+		fmt.Fprintln(w, event)
+	}
+	return nil
+}
+
+func printCombinedOutput(packages PackageList, eventsPerSecond TimepointTypeList, eventsLog OrderedVodLogList, errors []ErrorLogEntry) {
+	w, err := createOutputFile(outputFileName + "." + outputExtension())
+	if err != nil {
+		logError("%v", err)
+	}
+
+	switch outputFormat {
+	case jsonFormat:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(newCombinedRecord(packages, eventsPerSecond, eventsLog, errors))
+	case xmlFormat:
+		fmt.Fprint(w, xml.Header)
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		err = encoder.Encode(newCombinedRecord(packages, eventsPerSecond, eventsLog, errors))
+		fmt.Fprintln(w)
+	default:
+		err = writeCombinedSections(w, packages, eventsPerSecond, eventsLog, errors)
+	}
+	if err != nil {
+		logError("%v", err)
+	}
+	w.Close()
+}
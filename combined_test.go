@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// The single document of -combined -s json, a key for every output
+func TestCombinedJson(t *testing.T) {
+	dir := withOutputDir(t)
+	savedFormat, savedExt, savedName := outputFormat, outputExtensionOverride, outputFileName
+	defer func() { outputFormat, outputExtensionOverride, outputFileName = savedFormat, savedExt, savedName }()
+	outputFormat, outputExtensionOverride, outputFileName = jsonFormat, "", "output"
+
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	eventsPerSecond := TimepointTypeList{{start, 3}, {start.Add(time.Second), 1}}
+	vod := OrderedVodLogList{{timestamp: start, deviceId: "0000000001", eventcode: "VOD Category", mso: "MSO1"}}
+	errors := []ErrorLogEntry{newErrorLogEntry("a_MSO1.raw", 7, "bad line", errWrongLineFormat)}
+	printCombinedOutput(testPackages(), eventsPerSecond, vod, errors)
+
+	var document struct {
+		Packages []struct {
+			Timestamp time.Time `json:"timestamp"`
+			DeviceId  string    `json:"deviceId"`
+			EventCode string    `json:"eventCode"`
+		} `json:"packages"`
+		EventsPerSecond []struct {
+			Timestamp time.Time `json:"timestamp"`
+			Events    int       `json:"events"`
+		} `json:"eventsPerSecond"`
+		Errors []struct {
+			File     string `json:"file"`
+			LineNo   int    `json:"lineNo"`
+			Category string `json:"category"`
+			Line     string `json:"line"`
+		} `json:"errors"`
+		Vod []struct {
+			DeviceId  string `json:"deviceId"`
+			EventCode string `json:"eventCode"`
+			Mso       string `json:"mso"`
+		} `json:"vod"`
+	}
+	content := readOutput(t, dir, "output.json")
+	if err := json.Unmarshal([]byte(content), &document); err != nil {
+		t.Fatalf("output.json: %v\n%s", err, content)
+	}
+	if len(document.Packages) != 2 || document.Packages[1].EventCode != "Channel Change (verbose)" || !document.Packages[0].Timestamp.Equal(start) {
+		t.Errorf("packages %+v", document.Packages)
+	}
+	if len(document.EventsPerSecond) != 2 || document.EventsPerSecond[0].Events != 3 {
+		t.Errorf("eventsPerSecond %+v", document.EventsPerSecond)
+	}
+	if len(document.Errors) != 1 || document.Errors[0].Category != "format" || document.Errors[0].LineNo != 7 || document.Errors[0].Line != "bad line" {
+		t.Errorf("errors %+v", document.Errors)
+	}
+	if len(document.Vod) != 1 || document.Vod[0].EventCode != "VOD Category" || document.Vod[0].Mso != "MSO1" {
+		t.Errorf("vod %+v", document.Vod)
+	}
+}
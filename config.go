@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Settings of a processing run. The CLI builds one from the flags in init(),
+// Process and the parsers only read the Config they are given, so runs with
+// different settings can share the process. The code tables, the outputs and
+// the sinks are still package level.
+type Config struct {
+	// Files processed at a time
+	concurrency int
+	// Per worker counters of the pool, -workers-metrics
+	workerMetrics bool
+	// Workers per file, the lines are split by device Id
+	splitWorkers int
+	// Interim totals to stderr, -heartbeat
+	heartbeat time.Duration
+
+	// Input
+	inputFormat string
+	headLines   int
+	tailLines   int
+	sampleRate  float64
+
+	// Parsing
+	vodLog           bool
+	eventSequenceLog bool
+	minDate          time.Time
+	futureSkew       time.Duration
+	strictCodes      bool
+	sizeOverhead     int
+	// HMAC key of the -anonymize device Ids, nil keeps them as they are,
+	// and the anonymized Ids by the original ones, hashed once per device
+	anonymizeKey  []byte
+	anonymizedIds sync.Map
+	// Base of the clickstring timestamp digits, 16 or 10, and the width in bytes
+	timestampBase  int
+	timestampBytes int
+	// Framing bytes before the event code, counted in the event size
+	codeOffset int
+
+	// Buffer simulation
+	supress         bool
+	diagnosticsOnly bool
+	// -S window, the time of day from midnight, end excluded
+	suppressStart  time.Duration
+	suppressEnd    time.Duration
+	watermarkMode  string
+	minEventSize   int
+	initBuffer     string
+	initBufferFill int
+	// -seed, the clock one when not given, and the source of the random initial fills
+	seed              int64
+	random            *rand.Rand
+	randomLock        sync.Mutex
+	flushInterval     time.Duration
+	flushFinal        bool
+	bufferStats       bool
+	deviceActivity    bool
+	perFileStats      bool
+	bufferTraceDevice string
+	maxErrors         uint64
+}
+
+// Config with the flag defaults
+func newConfig() *Config {
+	minDate, _ := time.Parse(minDateLayout, defaultMinDate)
+	cfg := &Config{
+		concurrency:    100,
+		splitWorkers:   1,
+		inputFormat:    rawInput,
+		sampleRate:     1,
+		timestampBase:  16,
+		timestampBytes: defaultTimestampBytes,
+		minDate:        minDate,
+		initBuffer:     initBufferRandom,
+		watermarkMode:  watermarkOver,
+		suppressStart:  2 * time.Hour,
+		suppressEnd:    3 * time.Hour,
+	}
+	cfg.setSeed(0)
+	return cfg
+}
+
+// Seeds the random initial buffer fills, 0 seeds from the clock
+func (cfg *Config) setSeed(seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	cfg.seed = seed
+	cfg.random = rand.New(rand.NewSource(seed))
+}
+
+// Whether -S leaves the event out: a diagnostic one within the window. The window
+// may go over midnight, e.g. 23:00-01:00.
+func (cfg *Config) isSuppressed(eventCode string, timestamp time.Time) bool {
+	if !cfg.supress || !isDiagnosticEvent(eventCode) {
+		return false
+	}
+	hour, min, sec := timestamp.Clock()
+	clock := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+	if cfg.suppressStart <= cfg.suppressEnd {
+		return clock >= cfg.suppressStart && clock < cfg.suppressEnd
+	}
+	return clock >= cfg.suppressStart || clock < cfg.suppressEnd
+}
+
+// HH:MM time of day as the time from midnight
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("Wrong time of day %s, expected HH:MM", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Config of the command line run
+var runConfig = newConfig()
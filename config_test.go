@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsSuppressed(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2016, 3, 1, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		name       string
+		supress    bool
+		start, end string
+		code       string
+		timestamp  time.Time
+		want       bool
+	}{
+		{"in the default window", true, "02:00", "03:00", "`U`Unit Ident.", at(2, 30), true},
+		{"out of the default window", true, "02:00", "03:00", "`U`Unit Ident.", at(14, 0), false},
+		{"the end excluded", true, "02:00", "03:00", "`U`Unit Ident.", at(3, 0), false},
+		{"not a diagnostic event", true, "02:00", "03:00", "`P`Pulse", at(2, 30), false},
+		{"without -S", false, "02:00", "03:00", "`U`Unit Ident.", at(2, 30), false},
+		{"over midnight, before", true, "23:00", "01:00", "`X`Status", at(23, 30), true},
+		{"over midnight, after", true, "23:00", "01:00", "`X`Status", at(0, 30), true},
+		{"over midnight, out", true, "23:00", "01:00", "`X`Status", at(2, 30), false},
+	}
+	for _, test := range tests {
+		cfg := testConfig()
+		cfg.supress = test.supress
+		var err error
+		if cfg.suppressStart, err = parseClock(test.start); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.suppressEnd, err = parseClock(test.end); err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.isSuppressed(test.code, test.timestamp); got != test.want {
+			t.Errorf("%s: isSuppressed(%s, %v) = %v, want %v", test.name, test.code, test.timestamp, got, test.want)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	if clock, err := parseClock("02:30"); err != nil || clock != 2*time.Hour+30*time.Minute {
+		t.Errorf("parseClock(02:30) = %v, %v", clock, err)
+	}
+	for _, value := range []string{"2am", "25:00", "02:30:00", ""} {
+		if _, err := parseClock(value); err == nil {
+			t.Errorf("parseClock(%q), want an error", value)
+		}
+	}
+}
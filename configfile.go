@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Settings of a -config file by the flag name, e.g. {"d": "data", "c": 20, "mso": ["MSO1", "MSO2"]}
+// in json, or the same as the flat "name: value" lines of a .yaml/.yml file.
+// The file fills in the flags given neither on the command line nor in the environment.
+func loadConfigFile(fileName string) (map[string]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return parseYamlConfig(data)
+	}
+
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Large numbers as they are written, not in the float notation
+	decoder.UseNumber()
+	if err = decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("Wrong config %s: %v", fileName, err)
+	}
+	settings := make(map[string]string, len(values))
+	for name, value := range values {
+		switch value := value.(type) {
+		case []interface{}:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			settings[name] = strings.Join(items, ",")
+		case map[string]interface{}, nil:
+			return nil, fmt.Errorf("Wrong config %s: %s is not a flag value", fileName, name)
+		default:
+			settings[name] = fmt.Sprint(value)
+		}
+	}
+	return settings, nil
+}
+
+// Flat yaml only: "name: value" lines, # comments, quoted values and [a, b] lists
+func parseYamlConfig(data []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("Wrong config line %d: %s", lineNo, line)
+		}
+		value = strings.TrimSpace(value)
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			items := strings.Split(value[1:len(value)-1], ",")
+			for i := range items {
+				items[i] = strings.Trim(strings.TrimSpace(items[i]), `"'`)
+			}
+			value = strings.Join(items, ",")
+		} else {
+			value = strings.Trim(value, `"'`)
+		}
+		settings[strings.TrimSpace(name)] = value
+	}
+	return settings, scanner.Err()
+}
+
+// Sets the flags given neither on the command line nor in the environment from the config file,
+// the precedence is: the flag, the environment, the config file, then the default
+func applyConfigFile(fileName string) error {
+	settings, err := loadConfigFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range settings {
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("Wrong config %s: unknown flag %s", fileName, name)
+		}
+		if given[name] {
+			continue
+		}
+		if err = flag.Set(name, value); err != nil {
+			return fmt.Errorf("Wrong config %s: %s: %v", fileName, name, err)
+		}
+	}
+	return nil
+}
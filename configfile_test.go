@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// The same settings from the json and the flat yaml
+func TestLoadConfigFile(t *testing.T) {
+	want := map[string]string{"d": "data", "c": "20", "mso": "MSO1,MSO2", "P": "true", "watermark": "12345678901"}
+	files := map[string]string{
+		"run.json": `{"d": "data", "c": 20, "mso": ["MSO1", "MSO2"], "P": true, "watermark": 12345678901}`,
+		"run.yaml": "---\n# the nightly run\nd: data\nc: 20 # workers\nmso: [MSO1, 'MSO2']\nP: true\nwatermark: \"12345678901\"\n",
+	}
+	for name, content := range files {
+		settings, err := loadConfigFile(writeConfig(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(settings, want) {
+			t.Errorf("%s: %v, want %v", name, settings, want)
+		}
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	files := map[string]string{
+		"nested.json": `{"d": {"name": "data"}}`,
+		"null.json":   `{"d": null}`,
+		"broken.json": `{"d": "data"`,
+		"line.yml":    "d data\n",
+	}
+	for name, content := range files {
+		if _, err := loadConfigFile(writeConfig(t, name, content)); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+	if _, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing config: want an error")
+	}
+}
+
+// The flag given on the command line stays, the rest comes from the file
+func TestApplyConfigFile(t *testing.T) {
+	previousSplit, previousBatch := flag.Lookup("split").Value.String(), flag.Lookup("batch-size").Value.String()
+	defer func() {
+		flag.Set("split", previousSplit)
+		flag.Set("batch-size", previousBatch)
+	}()
+
+	if err := flag.Set("split", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(writeConfig(t, "run.json", `{"split": 7, "batch-size": 5}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := flag.Lookup("split").Value.String(); got != "3" {
+		t.Errorf("-split = %s, want the given 3", got)
+	}
+	if got := flag.Lookup("batch-size").Value.String(); got != "5" {
+		t.Errorf("-batch-size = %s, want 5 from the config", got)
+	}
+
+	for _, content := range []string{`{"no-such-flag": 1}`, `{"config": "other.json"}`, `{"exit-errors": "many"}`} {
+		if err := applyConfigFile(writeConfig(t, "wrong.json", content)); err == nil {
+			t.Errorf("%s: want an error", content)
+		}
+	}
+}
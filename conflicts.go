@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A flag as it takes part in a conflict, on when it is given
+type modeFlag struct {
+	name string
+	on   bool
+}
+
+// Groups of flags of which at most one may be given:
+//   - -P and -PC are two takes on primetime, -PC wins silently otherwise
+//   - -VOD and -L both write the events log, -VOD wins silently otherwise
+//   - -encode, -validate, -count-only, -merge, -errors-only and -list-codes are modes of their own, without the simulation
+//   - -combined writes a single output, no per MSO events per second files
+//   - -salt and -salt-file are two sources of the same -anonymize salt
+//
+// Anything else combines: -P/-PC with -VOD/-L, the MSO, size and sampling filters
+// with every mode, the outputs options with the simulation modes, and -S with
+// -diagnostics-only, the diagnostic events outside the -S window are left.
+func flagConflicts() [][]modeFlag {
+	return [][]modeFlag{
+		{{"P", primetimeOnly}, {"PC", cummulativePrimetimeOnly}},
+		{{"VOD", runConfig.vodLog}, {"L", runConfig.eventSequenceLog}},
+		{{"encode", encodeSpecFileName != ""}, {"validate", validate}, {"count-only", countOnly}, {"merge", mergeMode}, {"errors-only", errorsOnly}, {"list-codes", listCodes}},
+		{{"combined", combinedOutput}, {"eps-by-mso", eventsPerSecondByMso}},
+		{{"head", runConfig.headLines > 0}, {"tail", runConfig.tailLines > 0}},
+		{{"salt", anonymizeSalt != ""}, {"salt-file", saltFileName != ""}},
+	}
+}
+
+// Error listing the first group with more than one flag given
+func checkFlagConflicts(groups [][]modeFlag) error {
+	for _, group := range groups {
+		var given []string
+		for _, mode := range group {
+			if mode.on {
+				given = append(given, "-"+mode.name)
+			}
+		}
+		if len(given) > 1 {
+			return fmt.Errorf("Conflicting flags: %s can not be used together", strings.Join(given, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckFlagConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups [][]modeFlag
+		want   string
+	}{
+		{"nothing given", [][]modeFlag{{{"P", false}, {"PC", false}}}, ""},
+		{"one of a group", [][]modeFlag{{{"P", true}, {"PC", false}}, {{"VOD", true}, {"L", false}}}, ""},
+		{"two of a group", [][]modeFlag{{{"P", true}, {"PC", true}}}, "-P, -PC"},
+		{"the first group given twice", [][]modeFlag{
+			{{"VOD", false}, {"L", false}},
+			{{"encode", true}, {"validate", false}, {"merge", true}, {"list-codes", true}},
+			{{"salt", true}, {"salt-file", true}},
+		}, "-encode, -merge, -list-codes"},
+	}
+	for _, test := range tests {
+		err := checkFlagConflicts(test.groups)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want+" can not be used together")):
+			t.Errorf("%s: %v, want the conflict of %s", test.name, err, test.want)
+		}
+	}
+}
+
+// The groups take the settings as they are at the check
+func TestFlagConflictsFromSettings(t *testing.T) {
+	savedPrimetime, savedCumulative := primetimeOnly, cummulativePrimetimeOnly
+	savedVod, savedLog := runConfig.vodLog, runConfig.eventSequenceLog
+	savedSuppress, savedDiagnostics := runConfig.supress, runConfig.diagnosticsOnly
+	defer func() {
+		primetimeOnly, cummulativePrimetimeOnly = savedPrimetime, savedCumulative
+		runConfig.vodLog, runConfig.eventSequenceLog = savedVod, savedLog
+		runConfig.supress, runConfig.diagnosticsOnly = savedSuppress, savedDiagnostics
+	}()
+
+	primetimeOnly, cummulativePrimetimeOnly = true, false
+	runConfig.vodLog, runConfig.eventSequenceLog = true, false
+	runConfig.supress, runConfig.diagnosticsOnly = true, true
+	if err := checkFlagConflicts(flagConflicts()); err != nil {
+		t.Errorf("-P -VOD -S -diagnostics-only: %v", err)
+	}
+	runConfig.eventSequenceLog = true
+	if err := checkFlagConflicts(flagConflicts()); err == nil || !strings.Contains(err.Error(), "-VOD, -L") {
+		t.Errorf("-P -VOD -L: %v, want the -VOD, -L conflict", err)
+	}
+}
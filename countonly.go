@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Valid/invalid lines tally for a single input file
+type FileCount struct {
+	fileName string
+	valid    int
+	invalid  int
+}
+
+// Runs the input parser over the lines purely for validation, no buffers,
+// packages or output files are produced. The lines are the ones the processing
+// takes: of the -in-format, the -head/-tail ones and the -sample kept ones.
+func countEvents(cfg *Config, files []string, now time.Time) []FileCount {
+	counts := make([]FileCount, 0, len(files))
+
+	for _, fileName := range files {
+		logDebug("Counting: %s", fileName)
+		file, err := openInput(fileName)
+		if err != nil {
+			logWarn("Error opening file: %v", err)
+			continue
+		}
+		counts = append(counts, countFileEvents(cfg, fileName, file, now))
+		file.Close()
+	}
+	return counts
+}
+
+func countFileEvents(cfg *Config, fileName string, file io.Reader, now time.Time) FileCount {
+	mso := msoName(fileName)
+	count := FileCount{fileName: fileName}
+	scanner := newLineScanner(file)
+	headerLines := 0
+	var columns *CsvColumns
+	if cfg.inputFormat == csvInput {
+		if !scanner.Scan() {
+			return count
+		}
+		headerLines = 1
+		var err error
+		if columns, err = parseCsvHeader(scanner.Text()); err != nil {
+			logWarn("Skipping %s: %v", fileName, err)
+			count.invalid++
+			return count
+		}
+	}
+	reader := newLineReader(scanner, headerLines, cfg.headLines, cfg.tailLines)
+	sampler := cfg.newSampler(fileName)
+	for job, ok := reader.next(); ok; job, ok = reader.next() {
+		if cfg.isSampledOut(sampler) {
+			continue
+		}
+		if _, _, _, _, _, err := parseInputEvent(cfg, job.line, columns, nil, LineSource{mso, fileName, job.lineNo}, now); err != nil {
+			count.invalid++
+		} else {
+			count.valid++
+		}
+	}
+	if err := reader.Err(); err != nil {
+		logWarn("Error reading file %s: %v", fileName, err)
+		count.invalid++
+	}
+	return count
+}
+
+func printCountReport(counts []FileCount) {
+	totalValid, totalInvalid := 0, 0
+	for _, count := range counts {
+		fmt.Printf("%s:\t valid: %d\t errors: %d\n", count.fileName, count.valid, count.invalid)
+		totalValid += count.valid
+		totalInvalid += count.invalid
+	}
+	fmt.Println("Total valid events:\t", totalValid)
+	fmt.Println("Total parse errors:\t", totalInvalid)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// -count-only counts the lines the processing takes: csv, -head/-tail and -sample
+func TestCountEvents(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	raw := writeInput(t, dir, "a_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		"",
+		"dev2 not a clickstring",
+		rawLine(t, "dev2", "43", start.Add(time.Second), "0A0B"),
+		rawLine(t, "dev3", "50", start.Add(2*time.Second), ""))
+	csv := writeInput(t, dir, "b_MSO1.csv",
+		"timestamp, deviceId, eventCode, eventSize",
+		start.Format(csvTimeLayout)+", dev1, `P`Pulse, 5",
+		"not, an, event",
+		start.Add(time.Second).Format(csvTimeLayout)+", dev2, `P`Pulse, 5")
+
+	tests := []struct {
+		name    string
+		setup   func(cfg *Config)
+		files   []string
+		valid   int
+		invalid int
+	}{
+		{"raw", func(cfg *Config) {}, []string{raw}, 3, 1},
+		{"-head 2", func(cfg *Config) { cfg.headLines = 2 }, []string{raw}, 1, 1},
+		{"-tail 2", func(cfg *Config) { cfg.tailLines = 2 }, []string{raw}, 2, 0},
+		{"-in-format csv", func(cfg *Config) { cfg.inputFormat = csvInput }, []string{csv}, 2, 1},
+		{"-sample", func(cfg *Config) { cfg.sampleRate = 0.000001 }, []string{raw}, 0, 0},
+	}
+	for _, test := range tests {
+		cfg := testConfig()
+		test.setup(cfg)
+		counts := countEvents(cfg, test.files, time.Now())
+		if len(counts) != 1 || counts[0].valid != test.valid || counts[0].invalid != test.invalid {
+			t.Errorf("%s: %+v, want %d valid and %d invalid", test.name, counts, test.valid, test.invalid)
+		}
+	}
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"bufio"
-	"encoding/hex"
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"math/rand"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
@@ -19,74 +21,525 @@ import (
 
 var (
 	inFileName               string
+	globPattern              string
+	fileOrder                string
+	maxFiles                 int
+	filesOverLimit           int
+	anonymizeSalt            string
+	saltFileName             string
+	writeIdMap               bool
+	listCodes                bool
 	dirName                  string
 	inExtension              string
-	diagnostics              bool
 	outputFormat             string
 	outputFileName           string
-	concurrency              int
 	verbose                  bool
-	supress                  bool
 	singleFileMode           bool
 	primetimeOnly            bool
 	cummulativePrimetimeOnly bool
-	vodLogOn                 bool
-	eventSequenceLogOnly     bool
 	maxEventsPerFile         int
+	countOnly                bool
+	strict                   bool
+	msoFilter                map[string]bool
+	msoColumn                bool
+	eventsPerSecondByMso     bool
+	epsKey                   string
+	segment                  string
+	topSecondsNumber         int
+	smoothWindow             int
+	fillGaps                 bool
+	maxGap                   time.Duration
+	flatten                  bool
+	flattenName              string
+	dbSpec                   string
+	metricsFileName          string
+	manifestFileName         string
+	stateFileName            string
+	resetState               bool
+	maxLineSize              int
+	codesFileName            string
+	aliasesFileName          string
+	mergeCodes               string
+	keysFileName             string
+	replay                   bool
+	replaySpeed              float64
+	replayMaxSleep           time.Duration
+	httpSinkUrl              string
+	batchSize                int
+	httpTimeout              time.Duration
+	httpAbort                bool
+	kafkaBroker              string
+	kafkaTopic               string
+	kafkaFlush               time.Duration
+	encodeSpecFileName       string
+	validate                 bool
+	mergeMode                bool
+	errorsOnly               bool
+	exitMaxErrors            int
+	exitMinEvents            int
+	keepRaw                  bool
+	traceSource              bool
+	eventSizeColumn          bool
+	textEncoding             string
+	cpuProfileFileName       string
+	memProfileFileName       string
+	timingsOn                bool
+	maxErrorRate             float64
+	summaryJsonFileName      string
+	outputExtensionOverride  string
+	maxFileSize              int64
+	gzipOutput               bool
+	combinedOutput           bool
+	appendOutput             bool
+	outputDir                string
+	splitByMso               bool
 	appName                  string
 )
 
 const (
-	version      = "0.01"
-	txtOutput    = "csv"
-	UTC_GPS_Diff = 315964800
+	version             = "0.01"
+	defaultOutputFormat = csvFormat
+	// Packages output of -diagnostics-only
+	diagnosticsFileName = "diagnostics"
+	UTC_GPS_Diff        = 315964800
 	// iGuide R31 buff size
 	BuffWaterMarkSize = 750
 	rawExt            = "raw"
 	MAXEVENTLOGSIZE   = 250000
+	// Concatenated clickstream lines may exceed the bufio.Scanner 64KB default
+	scannerBufferSize  = 64 * 1024
+	scannerMaxLineSize = 1024 * 1024 // default for -maxline
+	minDateLayout      = "2006-01-02"
+	defaultMinDate     = "2000-01-01"
 )
 
 func init() {
-	flagFileName := flag.String("f", "", "Input `filename` to process")
-	flagDirName := flag.String("d", "", "Working `directory` for input files, default extension *.raw")
+	appName = os.Args[0]
+	command := subcommandOf(os.Args)
+	if command != nil {
+		flag.CommandLine = command.newFlagSet()
+	}
+
+	flagConfig := flag.String("config", "", "Settings `file`, json or flat yaml, by the flag names; the command line and CSBA_* variables override it")
+	flagFileName := flag.String("f", "", "Input `filename` to process, a zip, tar or tar.gz archive for all its entries")
+	flagDirName := flag.String("d", "", "Working `directory` for input files, default extension *.raw, the zip and tar archives in it are read too")
+	flagGlob := flag.String("glob", "", "Input files `pattern`, e.g. data/2024-*/clickstream_*.raw, ** matches any directories")
+	flagMaxFiles := flag.Int("max-files", 0, "Process only the first `N` files found, in the -order, the newest with mtime-desc, 0 is unlimited")
+	flagOrder := flag.String("order", orderName, "Processing `order` of the directory and glob files: name, mtime, mtime-desc or size")
 	flagExtension := flag.String("x", rawExt, "Input files `extension`: raw, cs")
-	flagDiagnostics := flag.Bool("t", false, "Turns `diagnostic` messages On")
-	flagOutputFormat := flag.String("s", txtOutput, "`Output format`s: txt, json, xml")
+	flagDiagnostics := flag.Bool("t", false, "Turns `diagnostic` messages On (same as -log-level debug)")
+	flagLogLevel := flag.String("log-level", "info", "Log `level`: debug, info, warn, error")
+	flagOutputFormat := flag.String("s", defaultOutputFormat, "`Output format`s: csv, txt, json, xml")
+	flagOutputExtension := flag.String("ext", "", "Output file `extension`, default depends on the output format")
 	flagOutputFile := flag.String("o", "output", "`Output filename`")
 	flagConcurrency := flag.Int("c", 100, "The number of files to process `concurrent`ly")
 	flagVerbose := flag.Bool("v", false, "`Verbose`: outputs to the screen")
-	flagSupress2am := flag.Bool("S", false, "`Supress`: 2am-3am diagnostics messages")
+	flagSupress2am := flag.Bool("S", false, "`Supress`: 2am-3am diagnostics messages, the window is set by -suppress-start and -suppress-end")
+	flagSuppressStart := flag.String("suppress-start", "02:00", "Start `time` of day, HH:MM, of the -S window")
+	flagSuppressEnd := flag.String("suppress-end", "03:00", "End `time` of day, HH:MM, of the -S window, excluded; before the start for a window over midnight")
+	flagDiagnosticsOnly := flag.Bool("diagnostics-only", false, "Keep only the `diagnostics` messages, with -S the ones outside its window, the packages go to diagnostics.<ext> unless -o is given")
 	flagPrimetime := flag.Bool("P", false, "`Primetime`: 8pm-11pm events only")
 	flagCombinedPrimetime := flag.Bool("PC", false, "`Cumulative Primetime`: 8pm-11pm events only cummulative single file")
 	flagVod := flag.Bool("VOD", false, "Create the log(s) for `VOD` activity")
 	flagEventSequenceLogOnly := flag.Bool("L", false, "Events sequence `log`")
 	flagMaxEventsPerFile := flag.Int("M", MAXEVENTLOGSIZE, "Max entries per event log csv file")
-
-	flag.Parse()
+	flagCountOnly := flag.Bool("count-only", false, "`Count` valid events and parse errors per file only, no outputs")
+	flagStrict := flag.Bool("strict", false, "`Strict` mode: fail on input files of the same MSO and date and on unreadable files")
+	flagMso := flag.String("mso", "", "Comma separated `MSO` list, process only files for these providers")
+	flagMsoColumn := flag.Bool("mso-column", false, "Add the `MSO` column to the packages output file")
+	flagEpsKey := flag.String("eps-key", epsKeyTime, "Events per second `key`: time, sod (seconds since midnight) or epoch (Unix seconds)")
+	flagSegment := flag.String("segment", "", "Also create `events per second` files for: weekday, weekend or dow (each day of the week)")
+	flagFillGaps := flag.Bool("fill-gaps", false, "Write the zero `gaps`, the seconds without events within a day, into the events per second files")
+	flagMaxGap := flag.Duration("max-gap", time.Hour, "Longest `gap` filled with -fill-gaps and -smooth, 0 is unlimited")
+	flagFlatten := flag.Bool("flatten", false, "Write the events per second and the VOD log of all the days into one `file` each, prefix-<flatten-name>.csv")
+	flagFlattenName := flag.String("flatten-name", "all", "File `name` part of the -flatten files")
+	flagSmooth := flag.Int("smooth", 0, "Write the `N` second moving average of the events per second to eventsPerSecondSmoothed.csv, 0 is off")
+	flagTopSeconds := flag.Int("top", 0, "List the `N` busiest seconds in topSeconds.csv, 0 is off")
+	flagEventsPerSecondByMso := flag.Bool("eps-by-mso", false, "Also create `events per second` files per MSO")
+	flagDb := flag.String("db", "", "Database `sink` for parsed events and packages, e.g. sqlite:events.db")
+	flagMetrics := flag.String("metrics", "", "Prometheus text format `metrics file` with the run summary")
+	flagList := flag.String("list", "", "`Manifest` file with the input file paths, one per line")
+	flagState := flag.String("state", "", "`State file` to skip already processed files on the next runs")
+	flagResetState := flag.Bool("reset-state", false, "Ignore the `state` file and process all the files")
+	flagMinDate := flag.String("min-date", defaultMinDate, "Events before this `date` (yyyy-mm-dd) are reported as errors")
+	flagFutureSkew := flag.Duration("future-skew", 0, "Allowed STB clock `skew` for events in the future, e.g. 5m")
+	flagMaxLine := flag.Int("maxline", scannerMaxLineSize, "Max input line size in `bytes`")
+	flagSplitWorkers := flag.Int("split", 1, "The number of `workers` per file, lines are split by device Id")
+	flagAnonymize := flag.Bool("anonymize", false, "Replace the device Ids in all the outputs with a salted `hash`, the same device gets the same one")
+	flagSalt := flag.String("salt", "", "`Salt` of the -anonymize hash, random for the run when neither it nor -salt-file is given")
+	flagSaltFile := flag.String("salt-file", "", "`File` keeping the -anonymize salt, created with a random one when missing")
+	flagIdMap := flag.Bool("idmap", false, "With -anonymize, write the sensitive original to anonymized device Ids `lookup` into idmap.csv")
+	flagBufferTrace := flag.String("buffer-trace", "", "Trace the buffer fill per event for the `device` Id, or all")
+	flagWorkerMetrics := flag.Bool("workers-metrics", false, "Per worker files, busy and idle `time` of the -c pool in workerStats.csv")
+	flagBufferStats := flag.Bool("buffer-stats", false, "Per device buffer `utilization` statistics")
+	flagFirstLast := flag.Bool("first-last", false, "Per device first and last event times in deviceActivity.csv")
+	flagPerFileStats := flag.Bool("per-file-stats", false, "Per input file lines, events, errors, packages, time range and bytes in perFileStats.csv")
+	flagWatermark := flag.String("watermark", watermarkOver, "Watermark `comparison`: > sends when the buffer would go over it (R31 model), >= also when it is reached exactly")
+	flagInitBuffer := flag.String("init-buffer", initBufferRandom, "Initial device buffer `fill`: random, zero or a number of bytes")
+	flagSeed := flag.Int64("seed", 0, "Random `seed` for the initial buffer fill and -sample, 0 seeds from the clock")
+	flagCodes := flag.String("codes", "", "Event `codes config` json file, extends or overrides the built-in codes")
+	flagAliases := flag.String("aliases", "", "Event `aliases` json file, maps built-in event names or hex codes to the output labels")
+	flagMergeCodes := flag.String("merge-codes", "", "Comma separated `from=into` code pairs counted as one in the event histogram, e.g. 43=63")
+	flagKeys := flag.String("keys", "", "Remote `keys` json file mapping hex keycodes to key names, enables the key press histogram")
+	flagReplay := flag.Bool("replay", false, "`Replay` the packages to stdout in real time, as they are sent")
+	flagReplaySpeed := flag.Float64("speed", 1, "Replay `speed` factor, 60 replays an hour in a minute")
+	flagReplayMaxSleep := flag.Duration("replay-max-sleep", 5*time.Second, "Longest replay `pause`, longer gaps are fast-forwarded")
+	flagHttpSink := flag.String("http-sink", "", "HTTP endpoint `url` to POST the packages to as json")
+	flagBatchSize := flag.Int("batch-size", 1000, "Packages per HTTP sink `batch`")
+	flagHttpTimeout := flag.Duration("http-timeout", 10*time.Second, "HTTP sink request `timeout`")
+	flagHttpAbort := flag.Bool("http-abort", false, "`Abort` the run when an HTTP sink batch fails after the retries")
+	flagKafka := flag.String("kafka", "", "Kafka `broker:port` to publish the events and packages to")
+	flagKafkaTopic := flag.String("topic", "", "Kafka `topic` name")
+	flagKafkaFlush := flag.Duration("kafka-flush", time.Second, "Kafka sink flush `interval`")
+	flagInputFormat := flag.String("in-format", rawInput, "Input `format`: raw clickstrings, or csv of already parsed events with a timestamp, deviceId, eventCode[, eventSize] header")
+	flagEncode := flag.String("encode", "", "Encode the events of the `spec` csv (timestamp, deviceId, eventCode[, payload][, received]) into raw lines on stdout")
+	flagListCodes := flag.Bool("list-codes", false, "Print the known event `codes`, with the -codes config applied, and exit; -s json for json")
+	flagErrorsOnly := flag.Bool("errors-only", false, "Write only the failing input lines with their file:lineNo to errorLines.txt, no simulation or outputs")
+	flagMerge := flag.Bool("merge", false, "`Merge` the packages or events per second csv outputs given as the arguments into re-sorted outputs")
+	flagValidate := flag.Bool("validate", false, "`Validate` the input files only, json report on stdout, no simulation or outputs")
+	flagMaxErrorRate := flag.Float64("max-error-rate", 0.01, "Highest parse error `rate` (0-1) that passes -validate")
+	flagExitErrors := flag.Int("exit-errors", 0, "Parse `errors` tolerated before the run exits with 2, -1 never")
+	flagExitMinEvents := flag.Int("exit-min-events", 1, "Valid `events` needed not to exit with 3, 0 never")
+	flagMaxErrors := flag.Uint64("max-errors", 0, "Abort the processing after this many parse `errors`, writing the partial outputs, 0 is unlimited")
+	flagSample := flag.Float64("sample", 1, "Process a random `fraction` (0-1] of the input lines, seeded by -seed and the file name")
+	flagMinSize := flag.Int("min-size", 0, "Leave out the events smaller than `bytes` in the buffer, the framing overhead included")
+	flagEventSize := flag.Bool("event-size", false, "Add the `event size` column, bytes in the buffer, to the VOD and events sequence logs")
+	flagTraceSource := flag.Bool("trace-source", false, "Add the `source` file and line number columns to the VOD and events sequence logs")
+	flagKeepRaw := flag.Bool("keep-raw", false, "Add the `raw` clickstring column to the VOD and events sequence logs")
+	flagTextEncoding := flag.String("text-encoding", asciiText, "Payload text `encoding`: ascii, utf16le, utf16be or auto")
+	flagTimestampBase := flag.Int("ts-base", 16, "`Base` of the clickstring timestamp digits: 16, or 10 for the decimal variants")
+	flagCodeOffset := flag.Int("code-offset", 0, "Framing `bytes` before the event code in the clickstring, the timestamp and payload follow the code")
+	flagTimestampBytes := flag.Int("ts-bytes", defaultTimestampBytes, "Clickstring timestamp width in `bytes`, 4 to 7, the codes config may set it per code")
+	flagStrictCodes := flag.Bool("strict-codes", false, "Unknown event `codes` are errors and dropped, instead of kept as UNKNOWN-<hex>")
+	flagHead := flag.Int("head", 0, "Process only the first `N` lines of each file")
+	flagTail := flag.Int("tail", 0, "Process only the last `N` lines of each file, kept in memory while reading the file through")
+	flagCpuProfile := flag.String("cpuprofile", "", "Write the CPU profile to the `file`")
+	flagMemProfile := flag.String("memprofile", "", "Write the heap profile at the end of the run to the `file`")
+	flagTimings := flag.Bool("timings", false, "Print the time spent scanning, parsing, sorting and writing")
+	flagSizeOverhead := flag.Int("size-overhead", 0, "Per event framing overhead in `bytes`, added to the event size")
+	flagFlushFinal := flag.Bool("flush-final", false, "Send the partially filled device buffers as `final` packages at the end of input")
+	flagMaxFileSize := flag.Int64("max-file-size", 0, "Roll the per day csv files over to -partN after this many `bytes`, 0 is no limit")
+	flagGzipOutput := flag.Bool("gzip-out", false, "`Gzip` the output files, .gz is appended to the names")
+	flagAppend := flag.Bool("append", false, "`Append` to the existing csv and txt output files instead of overwriting them, one run at a time per output directory")
+	flagCombined := flag.Bool("combined", false, "Write packages, events per second, VOD/events and error logs into a single `combined` output file")
+	flagOutputDir := flag.String("outdir", "", "Output `directory`, default is the current one")
+	flagSplitByMso := flag.Bool("split-by-mso", false, "Write each MSO outputs into its own `subdirectory` under -outdir")
+	flagHeartbeat := flag.Duration("heartbeat", 0, "Print interim totals to stderr every `interval`, e.g. 30s")
+	flagSummaryJson := flag.String("summary-json", "", "Write the run summary as json to the `file`")
+	flagFlushInterval := flag.Duration("flush-interval", 0, "Device buffer timer flush `interval`, e.g. 15m, 0 is watermark only")
+
+	if command != nil {
+		if err := command.parse(os.Args[2:]); err != nil {
+			subcommandUsage(err)
+		}
+	} else {
+		flag.Parse()
+	}
+	fromEnvironment, err := applyEnvironment()
+	if err != nil {
+		fmt.Println(err)
+		usage()
+	}
+	if *flagConfig != "" {
+		if err := applyConfigFile(*flagConfig); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+	}
+	// The level first, for the messages of the settings below
+	level, err := parseLogLevel(*flagLogLevel)
+	if err != nil {
+		fmt.Println(err)
+		usage()
+	}
+	logLevel = level
+	if *flagDiagnostics {
+		logLevel = DEBUG
+	}
+	for _, name := range fromEnvironment {
+		logDebug("-%s set from %s", name, envName(name))
+	}
 	if flag.Parsed() {
+		var err error
 		inFileName = *flagFileName
 		dirName = *flagDirName
+		globPattern = *flagGlob
+		fileOrder = *flagOrder
+		maxFiles = *flagMaxFiles
+		if maxFiles < 0 {
+			fmt.Println("Wrong max files:", maxFiles)
+			usage()
+		}
+		if fileOrder != orderName && fileOrder != orderMtime && fileOrder != orderMtimeDesc && fileOrder != orderSize {
+			fmt.Println("Wrong files order, expected name, mtime, mtime-desc or size:", fileOrder)
+			usage()
+		}
 		inExtension = *flagExtension
-		diagnostics = *flagDiagnostics
 		outputFormat = *flagOutputFormat
+		if !isKnownFormat(outputFormat) {
+			fmt.Println("Unknown output format:", outputFormat)
+			usage()
+		}
+		outputExtensionOverride = strings.TrimPrefix(*flagOutputExtension, ".")
 		outputFileName = *flagOutputFile
-		concurrency = *flagConcurrency
+		runConfig.concurrency = *flagConcurrency
 		verbose = *flagVerbose
-		supress = *flagSupress2am
+		runConfig.supress = *flagSupress2am
+		runConfig.diagnosticsOnly = *flagDiagnosticsOnly
+		if runConfig.suppressStart, err = parseClock(*flagSuppressStart); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+		if runConfig.suppressEnd, err = parseClock(*flagSuppressEnd); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+		if runConfig.suppressStart == runConfig.suppressEnd {
+			fmt.Println("Empty -S window:", *flagSuppressStart, *flagSuppressEnd)
+			usage()
+		}
+		if runConfig.diagnosticsOnly && !isFlagSet("o") {
+			outputFileName = diagnosticsFileName
+		}
 		primetimeOnly = *flagPrimetime
 		cummulativePrimetimeOnly = *flagCombinedPrimetime
-		vodLogOn = *flagVod
-		eventSequenceLogOnly = *flagEventSequenceLogOnly
+		runConfig.vodLog = *flagVod
+		runConfig.eventSequenceLog = *flagEventSequenceLogOnly
 		maxEventsPerFile = *flagMaxEventsPerFile
+		countOnly = *flagCountOnly
+		strict = *flagStrict
+		msoFilter = parseMsoFilter(*flagMso)
+		msoColumn = *flagMsoColumn
+		eventsPerSecondByMso = *flagEventsPerSecondByMso
+		epsKey = *flagEpsKey
+		segment = *flagSegment
+		topSecondsNumber = *flagTopSeconds
+		smoothWindow = *flagSmooth
+		fillGaps = *flagFillGaps
+		maxGap = *flagMaxGap
+		flatten = *flagFlatten
+		flattenName = *flagFlattenName
+		if flatten && flattenName == "" {
+			fmt.Println("Empty -flatten-name")
+			usage()
+		}
+		if smoothWindow < 0 {
+			fmt.Println("Wrong moving average window:", smoothWindow)
+			usage()
+		}
+		if topSecondsNumber < 0 {
+			fmt.Println("Wrong number of top seconds:", topSecondsNumber)
+			usage()
+		}
+		if !isKnownSegment(segment) {
+			fmt.Println("Wrong segment:", segment)
+			usage()
+		}
+		if epsKey != epsKeyTime && epsKey != epsKeySecondOfDay && epsKey != epsKeyEpoch {
+			fmt.Println("Wrong events per second key:", epsKey)
+			usage()
+		}
+		dbSpec = *flagDb
+		metricsFileName = *flagMetrics
+		manifestFileName = *flagList
+		stateFileName = *flagState
+		resetState = *flagResetState
+		runConfig.minDate, err = time.Parse(minDateLayout, *flagMinDate)
+		if err != nil {
+			fmt.Println("Wrong min date:", err)
+			usage()
+		}
+		runConfig.futureSkew = *flagFutureSkew
+		maxLineSize = *flagMaxLine
+		runConfig.splitWorkers = *flagSplitWorkers
+		runConfig.bufferTraceDevice = *flagBufferTrace
+		anonymizeSalt = *flagSalt
+		saltFileName = *flagSaltFile
+		writeIdMap = *flagIdMap
+		if writeIdMap && !*flagAnonymize {
+			fmt.Println("-idmap works with -anonymize only")
+			usage()
+		}
+		runConfig.bufferStats = *flagBufferStats
+		runConfig.workerMetrics = *flagWorkerMetrics
+		runConfig.deviceActivity = *flagFirstLast
+		runConfig.perFileStats = *flagPerFileStats
+		runConfig.watermarkMode = *flagWatermark
+		if runConfig.watermarkMode != watermarkOver && runConfig.watermarkMode != watermarkReached {
+			fmt.Println("Wrong watermark comparison, expected > or >=:", runConfig.watermarkMode)
+			usage()
+		}
+		runConfig.initBuffer, runConfig.initBufferFill, err = parseInitBuffer(*flagInitBuffer)
+		if err != nil {
+			fmt.Println(err)
+			usage()
+		}
+		runConfig.setSeed(*flagSeed)
+		codesFileName = *flagCodes
+		aliasesFileName = *flagAliases
+		mergeCodes = *flagMergeCodes
+		keysFileName = *flagKeys
+		replay = *flagReplay
+		replaySpeed = *flagReplaySpeed
+		replayMaxSleep = *flagReplayMaxSleep
+		httpSinkUrl = *flagHttpSink
+		batchSize = *flagBatchSize
+		httpTimeout = *flagHttpTimeout
+		httpAbort = *flagHttpAbort
+		kafkaBroker = *flagKafka
+		kafkaTopic = *flagKafkaTopic
+		kafkaFlush = *flagKafkaFlush
+		runConfig.inputFormat = *flagInputFormat
+		encodeSpecFileName = *flagEncode
+		validate = *flagValidate
+		mergeMode = *flagMerge
+		errorsOnly = *flagErrorsOnly
+		listCodes = *flagListCodes
+		runConfig.maxErrors = *flagMaxErrors
+		exitMaxErrors = *flagExitErrors
+		exitMinEvents = *flagExitMinEvents
+		runConfig.sampleRate = *flagSample
+		keepRaw = *flagKeepRaw
+		traceSource = *flagTraceSource
+		eventSizeColumn = *flagEventSize
+		runConfig.minEventSize = *flagMinSize
+		runConfig.strictCodes = *flagStrictCodes
+		runConfig.timestampBase = *flagTimestampBase
+		if runConfig.timestampBase != 10 && runConfig.timestampBase != 16 {
+			fmt.Println("Wrong timestamp base, expected 10 or 16:", runConfig.timestampBase)
+			usage()
+		}
+		runConfig.timestampBytes = *flagTimestampBytes
+		if !isTimestampWidth(runConfig.timestampBytes) {
+			fmt.Println("Wrong timestamp width, expected 4 to 7 bytes:", runConfig.timestampBytes)
+			usage()
+		}
+		runConfig.codeOffset = *flagCodeOffset
+		if runConfig.codeOffset < 0 {
+			fmt.Println("Wrong code offset:", runConfig.codeOffset)
+			usage()
+		}
+		textEncoding, err = parseTextEncoding(*flagTextEncoding)
+		if err != nil {
+			fmt.Println(err)
+			usage()
+		}
+		runConfig.headLines = *flagHead
+		cpuProfileFileName = *flagCpuProfile
+		memProfileFileName = *flagMemProfile
+		timingsOn = *flagTimings
+		runConfig.tailLines = *flagTail
+		if runConfig.headLines < 0 || runConfig.tailLines < 0 {
+			fmt.Println("Wrong head/tail lines:", runConfig.headLines, runConfig.tailLines)
+			usage()
+		}
+		if runConfig.sampleRate <= 0 || runConfig.sampleRate > 1 {
+			fmt.Println("Wrong sample rate:", runConfig.sampleRate)
+			usage()
+		}
+		maxErrorRate = *flagMaxErrorRate
+		if maxErrorRate < 0 || maxErrorRate > 1 {
+			fmt.Println("Wrong max error rate:", maxErrorRate)
+			usage()
+		}
+		switch runConfig.inputFormat {
+		case rawInput:
+		case csvInput:
+			if !isFlagSet("x") {
+				inExtension = csvInput
+			}
+		default:
+			fmt.Println("Unknown input format:", runConfig.inputFormat)
+			usage()
+		}
+		if kafkaFlush <= 0 {
+			fmt.Println("Wrong kafka flush interval:", kafkaFlush)
+			usage()
+		}
+		if batchSize <= 0 {
+			fmt.Println("Wrong batch size:", batchSize)
+			usage()
+		}
+		if replaySpeed <= 0 {
+			fmt.Println("Wrong replay speed:", replaySpeed)
+			usage()
+		}
+		runConfig.sizeOverhead = *flagSizeOverhead
+		runConfig.flushInterval = *flagFlushInterval
+		runConfig.flushFinal = *flagFlushFinal
+		summaryJsonFileName = *flagSummaryJson
+		runConfig.heartbeat = *flagHeartbeat
+		maxFileSize = *flagMaxFileSize
+		gzipOutput = *flagGzipOutput
+		combinedOutput = *flagCombined
+		appendOutput = *flagAppend
+		if appendOutput && (outputFormat == jsonFormat || outputFormat == xmlFormat || combinedOutput) {
+			fmt.Println("-append works with the csv and txt line outputs only")
+			usage()
+		}
+		outputDir = *flagOutputDir
+		splitByMso = *flagSplitByMso
+		if maxLineSize <= 0 {
+			fmt.Println("Wrong max line size:", maxLineSize)
+			usage()
+		}
+
+		if err := checkFlagConflicts(flagConflicts()); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+
+		if *flagAnonymize {
+			runConfig.anonymizeKey, err = anonymizeKey(anonymizeSalt, saltFileName)
+			if err != nil {
+				logError("Error reading the salt: %v", err)
+				exit(-1)
+			}
+			if runConfig.bufferTraceDevice != "" && runConfig.bufferTraceDevice != traceAllDevices {
+				// Traced by the original Id, the events carry the anonymized one
+				runConfig.bufferTraceDevice = runConfig.anonymize(runConfig.bufferTraceDevice)
+			}
+		} else if anonymizeSalt != "" || saltFileName != "" {
+			logWarn("-salt and -salt-file are used with -anonymize only")
+		}
 
-		appName = os.Args[0]
-		if inFileName == "" && dirName == "" && len(os.Args) == 2 {
+		if countOnly || validate || errorsOnly {
+			// Validation only, no event logs are collected
+			runConfig.vodLog = false
+			runConfig.eventSequenceLog = false
+		}
+
+		if inFileName == "" && dirName == "" && globPattern == "" && len(os.Args) == 2 && !mergeMode {
 			inFileName = os.Args[1]
+		} else if inFileName == "" && dirName == "" && globPattern == "" && command != nil && flag.NArg() == 1 {
+			inFileName = flag.Arg(0)
 		}
 	} else {
 		usage()
 	}
+	if codesFileName != "" {
+		if err := loadCodesConfig(codesFileName); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+	}
+	if keysFileName != "" {
+		if err := loadKeyNames(keysFileName); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+	}
+	if aliasesFileName != "" {
+		if err := loadAliases(aliasesFileName); err != nil {
+			fmt.Println(err)
+			usage()
+		}
+	}
 	initEventNames()
+	if err := parseMergeCodes(mergeCodes); err != nil {
+		fmt.Println(err)
+		usage()
+	}
 }
 
 func usage() {
@@ -95,24 +548,21 @@ func usage() {
 	fmt.Printf("\tprompt$>%s <filename>\n", appName)
 	fmt.Printf("\tprompt$>%s -f <filename> -d <dir> -o <outputfile> -s <outFormat> -t -v -x <extension>\n", appName)
 	fmt.Println("Provide either file or dir. Dir takes over file, if both provided")
+	fmt.Println("Flags not on the command line are taken from the CSBA_<FLAG> environment variables, e.g. CSBA_OUTDIR")
+	printSubcommands()
 	flag.Usage()
-	os.Exit(-1)
+	exit(-1)
 }
 
-func convertToTime(timestampS string) time.Time {
-	timestamp, err := strconv.ParseInt(timestampS, 16, 64)
-	//fmt.Println(timestampS, timestamp)
-	if err == nil {
-		timestamp += UTC_GPS_Diff
-		//fmt.Println(timestampS, timestamp)
-
-		t := time.Unix(timestamp, 0)
-		return t
+// GPS seconds in hex (or decimal, with -ts-base 10) to the UTC time, the error tells
+// a decode failure from a legitimate time close to the GPS epoch
+func convertToTime(timestampS string, base int) (time.Time, error) {
+	timestamp, err := strconv.ParseInt(timestampS, base, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", errWrongTimestamp, timestampS)
 	}
-	//else {
-	//fmt.Println("Error:", err)
-	//}
-	return time.Time{}
+	timestamp += UTC_GPS_Diff
+	return time.Unix(timestamp, 0), nil
 }
 
 type Command struct {
@@ -148,15 +598,29 @@ var commandsList = []Command{
 var (
 	eventNames       map[string]string
 	diagnosticEvents map[string]bool
+	// Event names by the code byte for the parser, "" for the unknown codes
+	eventNamesByCode [256]string
+	// UNKNOWN-<hex> names the unknown codes are kept under without -strict-codes
+	unknownNamesByCode [256]string
 )
 
+const unknownCodePrefix = "UNKNOWN-"
+
 func initEventNames() {
 	eventNames = make(map[string]string, len(commandsList))
 	diagnosticEvents = make(map[string]bool, 4)
+	eventNamesByCode = [256]string{}
+	for code := range unknownNamesByCode {
+		unknownNamesByCode[code] = fmt.Sprintf("%s%02X", unknownCodePrefix, code)
+	}
 	for _, cmd := range commandsList {
-		eventNames[cmd.cmd] = cmd.name
+		name := eventLabel(cmd)
+		eventNames[cmd.cmd] = name
+		if code, ok := decodeHexByte(cmd.cmd); ok {
+			eventNamesByCode[code] = name
+		}
 		if cmd.diagnostic {
-			diagnosticEvents[cmd.name] = cmd.diagnostic
+			diagnosticEvents[name] = cmd.diagnostic
 		}
 	}
 }
@@ -166,99 +630,153 @@ func isDiagnosticEvent(cmd string) bool {
 	return ok
 }
 
-func convertToString(str string) string {
-	bytes, err := hex.DecodeString(str)
-	if err == nil {
-		return string(bytes)
+// Whether the hex byte is the character, with no decoding allocations
+func isHexChar(hexByte string, c byte) bool {
+	const digits = "0123456789ABCDEF"
+	return len(hexByte) == 2 &&
+		strings.EqualFold(hexByte[0:1], digits[c>>4:c>>4+1]) &&
+		strings.EqualFold(hexByte[1:2], digits[c&0xF:c&0xF+1])
+}
+
+// Upper case hex digits only, as the codes in the commands list
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
 	}
-	return ""
+	return 0, false
 }
 
-func convertToLogName(cmd string) (string, error) {
-	cmdStr, ok := eventNames[cmd]
+func decodeHexByte(hexByte string) (byte, bool) {
+	if len(hexByte) < 2 {
+		return 0, false
+	}
+	high, okHigh := hexDigit(hexByte[0])
+	low, okLow := hexDigit(hexByte[1])
+	return high<<4 | low, okHigh && okLow
+}
+
+// Name of the event by the first clickstring byte, looked up in eventNamesByCode.
+// The unknown codes are kept as UNKNOWN-<hex>, they are errors with -strict-codes.
+func convertToLogName(clickString string, strict bool) (string, error) {
+	code, ok := decodeHexByte(clickString)
 	if !ok {
-		return "", errors.New("Unknown Clickstream Code")
+		return "", errUnknownCode
 	}
-	return cmdStr, nil
+	if eventNamesByCode[code] == "" {
+		if strict {
+			return "", errUnknownCode
+		}
+		return unknownNamesByCode[code], nil
+	}
+	return eventNamesByCode[code], nil
 }
 
 // just extract timestamp, device Id, and calculate event size
-func parseEvent(line string, eventLogChan chan<- EventLogEntry, mso string) (timestamp time.Time, deviceId string, eventSize int, eventCode string, err error) {
+// now is the wall clock reference for the whole run, captured once at startup
+func parseEvent(cfg *Config, line string, eventLogChan chan<- EventLogEntry, source LineSource, now time.Time) (timestamp time.Time, received string, deviceId string, eventSize int, eventCode string, err error) {
+	// Part of the line being parsed, for the panic message
+	field := "tokens"
 	defer func() {
+		// Last resort, the slices below are length checked
 		if r := recover(); r != nil {
-			timestamp = time.Now()
-			err = errors.New("Parser time exception")
+			logDebug("Panic parsing the %s of [%s]: %v\n%s", field, line, r, debug.Stack())
+			timestamp = now
+			err = fmt.Errorf("%w: panic parsing the %s: %v", errParserTime, field, r)
 		}
 	}()
 
-	var receivedIndex, deviceIndex, clickstringIndex int
-
-	tokens := strings.Split(line, " ")
-	switch len(tokens) {
-	case 2:
-		receivedIndex = -1
-		deviceIndex = 0
-		clickstringIndex = 1
-	case 3:
-		receivedIndex = 0
-		deviceIndex = 1
-		clickstringIndex = 2
+	// Index based, strings.Split would allocate the tokens for every line.
+	// Same tokens as splitting on every single space: "id  click" is 3 tokens.
+	var clickString string
+	first := strings.IndexByte(line, ' ')
+	if first < 0 {
+		return now, "", "", 0, "", errWrongLineFormat
+	}
+	second := strings.IndexByte(line[first+1:], ' ')
+	switch {
+	case second < 0:
+		received = "1900-01-01 00:00:00"
+		deviceId = cfg.anonymize(line[:first])
+		clickString = line[first+1:]
+	case strings.IndexByte(line[first+1+second+1:], ' ') < 0:
+		second += first + 1
+		received = line[:first]
+		deviceId = cfg.anonymize(line[first+1 : second])
+		clickString = line[second+1:]
 	default:
-		if diagnostics {
-			fmt.Println("Tokens were too many:", tokens)
+		if debugEnabled() {
+			logDebug("Tokens were too many: %s", line)
 		}
-		return time.Now(), "", 0, "", errors.New("Wrong line format")
+		return now, "", "", 0, "", errWrongLineFormat
 	}
-
-	deviceId = tokens[deviceIndex]
-	clickString := tokens[clickstringIndex]
-	var received string
-	if receivedIndex > -1 {
-		received = tokens[receivedIndex]
-	} else {
-		received = "1900-01-01 00:00:00"
+	if cfg.codeOffset > 0 {
+		// The framing bytes of the -code-offset wire format, the rest is the R31 clickstring
+		if len(clickString) < cfg.codeOffset*2 {
+			return now, "", "", 0, "", errWrongLineFormat
+		}
+		clickString = clickString[cfg.codeOffset*2:]
 	}
 
-	eventCode, err = convertToLogName(clickString[0:2])
+	field = "event code"
+	eventCode, err = convertToLogName(clickString, cfg.strictCodes)
 	if err != nil {
 		return
 	}
-	timestamp = convertToTime(clickString[2:10])
-	eventSize = len(clickString) / 2
-	err = nil
+	field = "timestamp"
+	width := timestampWidth(clickString, cfg.timestampBytes)
+	if len(clickString) < 2+width*2 {
+		return now, "", "", 0, "", fmt.Errorf("%w: %s, %d bytes expected", errWrongTimestamp, clickString[2:], width)
+	}
+	timestamp, err = convertToTime(clickString[2:2+width*2], cfg.timestampBase)
+	if err != nil {
+		return now, "", "", 0, "", err
+	}
+	field = "event size"
+	eventSize = calculateEventSize(clickString, cfg.sizeOverhead+cfg.codeOffset)
 
-	if diagnostics {
-		fmt.Printf("STB Id: %s \t eventCode: %s\t timeStamp: %v \t eventSize: %d\n",
+	if debugEnabled() {
+		// The arguments would be boxed on every line even with the debug level off
+		logDebug("STB Id: %s \t eventCode: %s\t timeStamp: %v \t eventSize: %d",
 			deviceId, eventCode, timestamp, eventSize)
 	}
 
-	if timestamp.After(time.Now()) {
-		err = errors.New("Wrong date: " + timestamp.String())
+	if timestamp.After(now.Add(cfg.futureSkew)) || timestamp.Before(cfg.minDate) {
+		err = fmt.Errorf("%w: %v", errWrongDate, timestamp)
 	}
 
-	if vodLogOn {
-		if ok, logEntry := checkAndLogForVodActivity(eventCode, timestamp, received, deviceId, clickString, mso); ok == true {
+	field = "payload"
+	if cfg.vodLog {
+		if ok, logEntry := checkAndLogForVodActivity(eventCode, timestamp, received, deviceId, eventSize, clickString, width-defaultTimestampBytes, source); ok == true {
 			eventLogChan <- logEntry
 		}
-	} else if eventSequenceLogOnly {
-		eventLogChan <- EventLogEntry{timestamp, received, deviceId, eventCode, mso}
+	} else if cfg.eventSequenceLog {
+		eventLogChan <- EventLogEntry{timestamp, received, deviceId, eventCode, source.mso, eventSize, rawClickString(clickString), source.fileName, source.lineNo}
 	}
 	return
 }
 
-func checkAndLogForVodActivity(eventCode string, timestamp time.Time, received string, deviceId string, clickString string, mso string) (bool, EventLogEntry) {
-	switch eventCode {
-	case "`G`VOD Category": // "47": // G
-		return true, EventLogEntry{timestamp, received, deviceId, eventCode, mso}
-	case "`I`Info Screen": // "49": // I
-		if convertToString(clickString[10:12]) == "V" {
-			return true, EventLogEntry{timestamp, received, deviceId, eventCode + " / Type V", mso}
+// shift is the timestamp bytes over the 4 the payload offsets count with
+func checkAndLogForVodActivity(eventCode string, timestamp time.Time, received string, deviceId string, eventSize int, clickString string, shift int, source LineSource) (bool, EventLogEntry) {
+	// By the code, the name may be aliased
+	switch clickString[0:2] {
+	case "47": // G, VOD Category
+		return true, EventLogEntry{timestamp, received, deviceId, eventCode, source.mso, eventSize, rawClickString(clickString), source.fileName, source.lineNo}
+	case "49": // I, Info Screen
+		if isPayloadChar(clickString, 10+shift*2, 'V') {
+			return true, EventLogEntry{timestamp, received, deviceId, eventCode + " / Type V", source.mso, eventSize, rawClickString(clickString), source.fileName, source.lineNo}
 		}
-	case "`V`Video Playback Session (non- OCAP)": // "56": // V
-		if convertToString(clickString[26:28]) == "V" {
-			return true, EventLogEntry{timestamp, received, deviceId, eventCode + " / Source V", mso}
+	case "56": // V, Video Playback Session (non- OCAP)
+		if isPayloadChar(clickString, 26+shift*2, 'V') {
+			return true, EventLogEntry{timestamp, received, deviceId, eventCode + " / Source V", source.mso, eventSize, rawClickString(clickString), source.fileName, source.lineNo}
 		}
 	default:
+		// Channel changes and other codes with the payload in the codes config
+		if payload, ok := decodePayload(clickString, shift); ok {
+			return true, EventLogEntry{timestamp, received, deviceId, eventCode + " / " + payload, source.mso, eventSize, rawClickString(clickString), source.fileName, source.lineNo}
+		}
 		return false, EventLogEntry{}
 	}
 	return false, EventLogEntry{}
@@ -270,6 +788,36 @@ type EventLogEntry struct {
 	deviceId  string
 	eventcode string
 	mso       string
+	// Bytes the event takes in the buffer, in the logs with -event-size
+	size int
+	// Undecoded clickstring with -keep-raw
+	raw string
+	// Input file and line of the event, in the logs with -trace-source
+	sourceFile string
+	lineNo     int
+}
+
+func (entry EventLogEntry) String() string {
+	text := fmt.Sprintf("%v, %v, %v, %v, %v",
+		entry.timestamp, entry.received, entry.deviceId, entry.eventcode, entry.mso)
+	if eventSizeColumn {
+		text += fmt.Sprintf(", %d", entry.size)
+	}
+	if keepRaw {
+		text += ", " + entry.raw
+	}
+	if traceSource {
+		text += fmt.Sprintf(", %s, %d", entry.sourceFile, entry.lineNo)
+	}
+	return text
+}
+
+// The clickstring is only kept in the log entries with -keep-raw
+func rawClickString(clickString string) string {
+	if !keepRaw {
+		return ""
+	}
+	return clickString
 }
 
 type ErrorLogEntry struct {
@@ -277,32 +825,21 @@ type ErrorLogEntry struct {
 	lineNo   int
 	line     string
 	err      error
+	category ErrorCategory
 }
 
 var errorsLog []ErrorLogEntry = []ErrorLogEntry{}
 
-func logErrorEvent(fileName, line string, lineNo int, err error) {
-	entry := ErrorLogEntry{
-		fileName,
-		lineNo,
-		line,
-		err,
-	}
-	errorsLog = append(errorsLog, entry)
-}
-
-func printErrorLogs() {
-	file, err := os.Create("errorlog.txt")
+func printErrorLogs(errors []ErrorLogEntry) {
+	w, err := createOutputFile("errorlog.txt")
 	if err != nil {
-		fmt.Println(err)
+		logError("%v", err)
 	}
-	w := bufio.NewWriter(file)
-	for _, logEntry := range errorsLog {
-		fmt.Fprintf(w, "File: %s \t lineNo: %d\t Error:%s\nEntry:[%s]\n",
-			logEntry.fileName, logEntry.lineNo, logEntry.err, logEntry.line)
+	for _, logEntry := range errors {
+		fmt.Fprintf(w, "File: %s \t lineNo: %d\t Category: %s\t Error:%s\nEntry:[%s]\n",
+			logEntry.fileName, logEntry.lineNo, logEntry.category, logEntry.err, logEntry.line)
 	}
-	w.Flush()
-	file.Close()
+	w.Close()
 }
 
 // Single Clickstream package "sending"
@@ -310,9 +847,13 @@ type Package struct {
 	timestamp time.Time
 	deviceId  string
 	eventCode string
+	mso       string
 }
 
 func (pkg Package) String() string {
+	if msoColumn {
+		return fmt.Sprintf("%v, %s, %s, %s", pkg.timestamp, pkg.deviceId, pkg.eventCode, pkg.mso)
+	}
 	return fmt.Sprintf("%v, %s, %s", pkg.timestamp, pkg.deviceId, pkg.eventCode)
 }
 
@@ -326,60 +867,167 @@ func (list PackageList) Swap(i, j int) {
 	list[i], list[j] = list[j], list[i]
 }
 
+// Same second packages by the device, then the event, and keep their order otherwise
+// with sort.Stable, so that the outputs are reproducible
 func (list PackageList) Less(i, j int) bool {
-	return list[i].timestamp.Before(list[j].timestamp)
+	if !list[i].timestamp.Equal(list[j].timestamp) {
+		return list[i].timestamp.Before(list[j].timestamp)
+	}
+	if list[i].deviceId != list[j].deviceId {
+		return list[i].deviceId < list[j].deviceId
+	}
+	return list[i].eventCode < list[j].eventCode
 }
 
 // Emulate sending of one Clickstream Package
-func Pack(timestamp time.Time, deviceId, eventCode string) Package {
+func Pack(timestamp time.Time, deviceId, eventCode, mso string) Package {
 	pkg := Package{}
 
 	pkg.deviceId = deviceId
 	pkg.timestamp = timestamp
 	pkg.eventCode = eventCode
+	pkg.mso = mso
 
-	if diagnostics {
-		fmt.Println(pkg)
-	}
+	logDebug("%v", pkg)
 	return pkg
 }
 
 func printOutputFile(packages PackageList) {
-	sort.Sort(packages)
+	sort.Stable(packages)
 
-	file, err := os.Create(outputFileName + "." + outputFormat)
+	w, err := createOutputFile(outputFileName + "." + outputExtension())
 	if err != nil {
-		fmt.Println(err)
+		logError("%v", err)
 	}
-	w := bufio.NewWriter(file)
-	for _, pkg := range packages {
-		//		if primetimeOnly {
-		//			if pkg.timestamp.Hour() >= 20.0 && pkg.timestamp.Hour() < 11.0 {
-		//				fmt.Fprintln(w, pkg)
-		//			}
-		//		} else {
-		fmt.Fprintln(w, pkg)
-		//		}
+	if err = writePackages(w, packages); err != nil {
+		logError("%v", err)
 	}
-	w.Flush()
-	file.Close()
+	w.Close()
 }
 
+const unknownMso = "unknown"
+
+// Input lines scanner, up to -maxline bytes a line
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	bufferSize := scannerBufferSize
+	if bufferSize > maxLineSize {
+		bufferSize = maxLineSize
+	}
+	scanner.Buffer(make([]byte, bufferSize), maxLineSize)
+	scanner.Split(scanInputLines())
+	return scanner
+}
+
+// UTF-8 byte order mark, Windows tools put it at the start of the files
+const utf8Bom = "\uFEFF"
+
+// bufio.ScanLines, with the byte order mark dropped from the first line.
+// ScanLines already drops the \r of the CRLF line endings.
+func scanInputLines() bufio.SplitFunc {
+	first := true
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = bufio.ScanLines(data, atEOF)
+		if first && token != nil {
+			first = false
+			token = bytes.TrimPrefix(token, []byte(utf8Bom))
+		}
+		return
+	}
+}
+
+// MSO is encoded in the file name as prefix_MSO.ext
 func msoName(fileName string) string {
-	mso := fileName[strings.LastIndex(fileName, "_")+1 : strings.LastIndex(fileName, ".")]
-	return mso
+	name := inputBaseName(fileName)
+	start := strings.LastIndex(name, "_")
+	if start < 0 {
+		return unknownMso
+	}
+	end := strings.LastIndex(name, ".")
+	if end < 0 {
+		end = len(name)
+	}
+	if end <= start+1 {
+		return unknownMso
+	}
+	return name[start+1 : end]
 }
 
 func main() {
 	startTime := time.Now()
-	rand.Seed(int64(startTime.Second()))
+	startProfiling()
+	defer stopProfiling()
+
+	if listCodes {
+		if err := printCodes(os.Stdout, outputFormat); err != nil {
+			logError("%v", err)
+			exit(-1)
+		}
+		return
+	}
+
+	if encodeSpecFileName != "" {
+		encoded, err := encodeEvents(runConfig, encodeSpecFileName, os.Stdout)
+		if err != nil {
+			logError("%v", err)
+			exit(-1)
+		}
+		logInfo("Encoded %d events", encoded)
+		return
+	}
+
+	if validate {
+		report, err := validateFiles(runConfig, getFilesToProcess(), maxErrorRate, startTime, os.Stdout)
+		if err != nil {
+			logError("%v", err)
+			exit(-1)
+		}
+		if !report.Passed {
+			logError("Validation failed: error rate %.4f, max %.4f", report.ErrorRate, maxErrorRate)
+			exit(-1)
+		}
+		return
+	}
+
+	if mergeMode {
+		makeOutputDir()
+		merged, err := mergeOutputs(flag.Args())
+		if err != nil {
+			logError("%v", err)
+			exit(-1)
+		}
+		fmt.Printf("Merged %d files, %d entries in %v\n", flag.NArg(), merged, time.Since(startTime))
+		return
+	}
+
+	if errorsOnly {
+		makeOutputDir()
+		files := getFilesToProcess()
+		errorLines := collectErrorLines(runConfig, files, startTime)
+		if err := printErrorLines(errorLines); err != nil {
+			logError("%v", err)
+			exit(-1)
+		}
+		fmt.Printf("Processed %d files in %v, %d error lines\n", len(files), time.Since(startTime), len(errorLines))
+		return
+	}
+
+	if countOnly {
+		files := getFilesToProcess()
+		printCountReport(countEvents(runConfig, files, startTime))
+		fmt.Printf("Processed %d files in %v\n", len(files), time.Since(startTime))
+		return
+	}
+
 	var wg sync.WaitGroup
 
 	eventLogChan := make(chan EventLogEntry)
 	var vodLog OrderedVodLogList
 
+	makeOutputDir()
+
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		for {
 			logEntry, more := <-eventLogChan
 			if more {
@@ -387,7 +1035,7 @@ func main() {
 				vodLog = append(vodLog, logEntry)
 				mutex.Unlock()
 
-				if eventSequenceLogOnly && len(vodLog) == maxEventsPerFile {
+				if runConfig.eventSequenceLog && !combinedOutput && !splitByMso && len(vodLog) == maxEventsPerFile {
 					// We have reached max log size
 					// Save what we have and start over with new one
 					mutex.Lock()
@@ -407,95 +1055,144 @@ func main() {
 		}
 	}()
 
-	packages := []Package{}
+	if dbSpec != "" {
+		sink, err := openDbSink(dbSpec, startTime.Format(time.RFC3339Nano))
+		if err != nil {
+			logError("Error opening database sink: %v", err)
+			exit(-1)
+		}
+		dbSink = sink
+	}
+	if kafkaBroker != "" {
+		sink, err := openKafkaSink(kafkaBroker, kafkaTopic, kafkaFlush)
+		if err != nil {
+			logError("Error opening kafka sink: %v", err)
+			exit(-1)
+		}
+		kafkaSink = sink
+	}
 
 	files := getFilesToProcess() //getFiles()
 
-	totalEvents := 0
 	// BufferSizes for devices
-	bufferSize := make(map[string]int)
-
-	for _, fileName := range files {
-		if diagnostics {
-			fmt.Println("Processing: ", fileName)
+	buffers := newBufferState()
+
+	ctx, stopInterrupt := withInterrupt(context.Background())
+	defer stopInterrupt()
+
+	result, err := Process(ctx, runConfig, files, eventLogChan, buffers, startTime)
+	interrupted := err != nil
+	tooManyErrors := errors.Is(err, errMaxErrors)
+	if tooManyErrors {
+		logError("Aborting after %d parse errors (-max-errors), %d of %d files done", runConfig.maxErrors, result.files, len(files))
+	} else if interrupted {
+		logWarn("Processing stopped: %v, %d of %d files done", err, result.files, len(files))
+	}
+	errorsLog = append(errorsLog, result.errors...)
+	bufferTrace = result.trace
+	msoStats = result.msoStats
+	unknownCodes = result.unknownCodes
+	if processingState != nil {
+		for fileName, read := range result.processed {
+			processingState.markProcessed(fileName, read)
 		}
-		file, err := os.Open(fileName)
-		if err != nil {
-			fmt.Println("Error opening file: ", err)
-			continue
-		}
-		mso := msoName(fileName)
-		scanner := bufio.NewScanner(file)
-		lineNo := 0
-		for scanner.Scan() {
-			line := scanner.Text()
-			lineNo++
-			if diagnostics {
-				fmt.Println("Got next line: ", line)
-			}
-			timestamp, deviceId, eventSize, eventCode, err := parseEvent(line, eventLogChan, mso)
-
-			if diagnostics {
-				fmt.Println("Parsed into: ", timestamp, deviceId, eventSize, eventCode, err)
-			}
+	}
 
-			if err != nil {
-				logErrorEvent(fileName, line, lineNo, err)
-			} else {
-				if _, ok := bufferSize[deviceId]; !ok {
-					// First occurence
-					bufferSize[deviceId] = rand.Intn(BuffWaterMarkSize)
-				}
-				if diagnostics {
-					fmt.Println("Buff: ", bufferSize[deviceId])
-					fmt.Println("Watermark:", BuffWaterMarkSize)
-				}
-
-				if supress && isDiagnosticEvent(eventCode) {
-					// If supress diagnostic commands is requested, then ignore them
-					if diagnostics {
-						fmt.Println("Skipped:", timestamp, deviceId, eventSize, eventCode, err)
-					}
-				} else {
-					if bufferSize[deviceId]+eventSize > BuffWaterMarkSize {
-						pkg := Pack(timestamp, deviceId, eventCode)
-						// Send a new package
-						packages = append(packages, pkg)
-						if diagnostics {
-							fmt.Println("Sent package: ", pkg)
-						}
-						// Start the buffer from the beginning
-						bufferSize[deviceId] = eventSize
-					} else {
-						bufferSize[deviceId] += eventSize
-					}
-				}
-			}
+	packages := result.packages
+	totalEvents := result.totalEvents
+	validEvents := result.validEvents
+	skippedFiles := result.skippedFiles
 
+	if runConfig.flushFinal {
+		for _, pkg := range buffers.flushAll() {
+			packages = append(packages, pkg)
+			getMsoStats(pkg.mso).addPackage(pkg)
 		}
-		totalEvents += lineNo
-		file.Close()
 	}
+	start := startTiming()
+	sortErrorsLog()
+	sort.Stable(PackageList(packages))
+	addTiming(&timings.sort, start)
 
 	// closing the eventLogChannel
 	close(eventLogChan)
 
 	wg.Wait()
 
-	if !eventSequenceLogOnly {
-		printOutputFile(packages)
+	var max TimepointType
+	var avg, total int
+	start = startTiming()
+	if splitByMso {
+		max, avg, total = printOutputsByMso(packages, vodLog, errorsLog)
+	} else {
+		max, avg, total = printOutputs(packages, vodLog, errorsLog)
+	}
+	addTiming(&timings.write, start)
+
+	if dbSink != nil {
+		dbSink.addPackages(packages)
+		if err := dbSink.Close(); err != nil {
+			logError("Error closing database sink: %v", err)
+		}
+	}
+	if kafkaSink != nil {
+		kafkaSink.addPackages(packages)
+		if err := kafkaSink.Close(); err != nil {
+			logError("Error closing kafka sink: %v", err)
+		}
 	}
 
-	max, avg, total := printEventsPerSecond(packages)
-	if vodLogOn {
-		printVodLogEntries(vodLog)
-	} else if eventSequenceLogOnly {
-		printAllEvents(vodLog)
+	if httpSinkUrl != "" {
+		sink := newHttpSink(httpSinkUrl, batchSize, httpTimeout, httpAbort)
+		accepted, err := sink.send(packages)
+		fmt.Printf("Packages posted to %s: %d of %d\n", httpSinkUrl, accepted, len(packages))
+		if err != nil && httpAbort {
+			logError("HTTP sink aborted: %v", err)
+			exit(-1)
+		}
 	}
 
-	printErrorLogs()
-	fmt.Println("Number of devices:\t", len(bufferSize))
+	if replay && !interrupted {
+		if sent := replayPackages(ctx, packages, replaySpeed, replayMaxSleep, os.Stdout); sent < len(packages) {
+			logWarn("Replay stopped after %d of %d packages", sent, len(packages))
+		}
+	}
+
+	if runConfig.bufferTraceDevice != "" {
+		printBufferTrace()
+	}
+	if runConfig.bufferStats {
+		printBufferStats(buffers)
+	}
+	if runConfig.deviceActivity {
+		printDeviceActivity(buffers)
+	}
+	if result.pool != nil {
+		printWorkerStats(result.pool)
+	}
+	if runConfig.perFileStats {
+		printFileStats(result.fileStats)
+	}
+	if writeIdMap {
+		if err := printIdMap(runConfig); err != nil {
+			logError("Error writing the device Ids map: %v", err)
+		}
+	}
+	fmt.Println("Number of devices:\t", buffers.devices())
 	fmt.Println("Total events: \t\t", totalEvents)
+	if runConfig.sampleRate < 1 {
+		fmt.Printf("Sampled at %g: %d lines left out, the totals are of the sample\n", runConfig.sampleRate, result.sampledOut)
+	}
+	if runConfig.minEventSize > 0 {
+		fmt.Printf("Events below %d bytes left out: %d\n", runConfig.minEventSize, result.smallEvents)
+	}
+	if result.blankLines > 0 {
+		fmt.Println("Blank lines skipped:\t", result.blankLines)
+	}
+	bytesPerDevice, totalBytes := buffers.deviceBytes()
+	fmt.Println("Total bytes: \t\t", totalBytes)
+	fmt.Printf("Bytes per device: \t %.1f\n", averageSize(totalBytes, len(bytesPerDevice)))
+	fmt.Printf("Average event size: \t %.1f\n", averageSize(totalBytes, validEvents))
 	fmt.Println("Total packages:\t\t", len(packages))
 	if len(packages) > 0 {
 		fmt.Println("First package sent at: ", packages[0].timestamp)
@@ -504,10 +1201,120 @@ func main() {
 		fmt.Println("No packages were sent")
 	}
 	fmt.Println("Error entries number: ", len(errorsLog))
+	errorsByCategory := countErrorsByCategory(errorsLog)
+	for _, category := range errorCategoryNames {
+		fmt.Printf("\t%s errors: %d\n", category, errorsByCategory[category])
+	}
+	fmt.Println("Files skipped, could not open: ", skippedFiles)
+	fmt.Println("Empty files, no events: ", result.emptyFiles)
+	if filesOverLimit > 0 {
+		fmt.Printf("Files left out by -max-files %d: %d, the results are of a sample\n", maxFiles, filesOverLimit)
+	}
+	printFileCollisions()
+	if len(msoFilter) > 0 {
+		fmt.Println("Files skipped by MSO filter: ", msoSkippedFiles)
+	}
+	if processingState != nil {
+		processingState.save(stateFileName)
+		fmt.Println("Files skipped as already processed: ", stateSkippedFiles)
+	}
 	fmt.Println("Total reported at times: ", total)
 	fmt.Printf("Max per second: %d at %v\n", max.numberOfEvents, max.timestamp)
-	fmt.Println("Average per second: ", avg)
-	fmt.Printf("Processed %d files in %v\n", len(files), time.Since(startTime))
+	averageActive, averageOverSpan, span := averagesPerSecond(eventsPerSecondList(packages))
+	fmt.Printf("Average per active second: %.3f\n", averageActive)
+	fmt.Printf("Average per second over the %v span: %.3f\n", span, averageOverSpan)
+	if topSecondsNumber > 0 {
+		printTopSeconds(packages, topSecondsNumber)
+	}
+	if smoothWindow > 0 {
+		printSmoothedEventsPerSecond(packages, smoothWindow)
+	}
+	printSummaryByMso()
+	printEventHistogram()
+	printUnknownCodes()
+	if keyNames != nil {
+		printKeyPressHistogram()
+	}
+	if metricsFileName != "" {
+		printMetrics(metricsFileName, totalEvents, len(packages), len(errorsLog), buffers.devices(), max.numberOfEvents)
+	}
+	if summaryJsonFileName != "" {
+		printJsonSummary(summaryJsonFileName, Summary{
+			Files:                  result.files,
+			FilesLeftOut:           filesOverLimit,
+			SampleRate:             runConfig.sampleRate,
+			Devices:                buffers.devices(),
+			TotalEvents:            totalEvents,
+			ValidEvents:            validEvents,
+			TotalPackages:          len(packages),
+			ParseErrors:            len(errorsLog),
+			ErrorsByCategory:       errorsByCategory,
+			TotalBytes:             totalBytes,
+			AverageEventSize:       averageSize(totalBytes, validEvents),
+			BytesPerDevice:         bytesPerDevice,
+			MaxPerSecond:           max.numberOfEvents,
+			MaxPerSecondAt:         max.timestamp,
+			AveragePerSecond:       avg,
+			AveragePerActiveSecond: averageActive,
+			AveragePerSpanSecond:   averageOverSpan,
+			SpanSeconds:            int(span.Seconds()),
+			Elapsed:                time.Since(startTime).String(),
+		})
+	}
+	fmt.Printf("Processed %d files in %v\n", result.files, time.Since(startTime))
+	if timingsOn {
+		printTimings(time.Since(startTime))
+	}
+	stopProfiling()
+
+	if tooManyErrors {
+		fmt.Println("Aborted on too many parse errors, the results above are partial")
+		exit(-1)
+	}
+	if interrupted {
+		fmt.Println("Interrupted, the results above are partial")
+		exit(exitInterrupted)
+	}
+
+	if strict && skippedFiles > 0 {
+		logError("%d file(s) could not be opened in strict mode", skippedFiles)
+		exit(-1)
+	}
+
+	if code := dataHealthExitCode(len(errorsLog), validEvents); code != exitClean {
+		logWarn("Exiting with %d: %d parse errors, %d valid events", code, len(errorsLog), validEvents)
+		exit(code)
+	}
+}
+
+// Packages, events per second, VOD/events and error logs of one data set
+func printOutputs(packages PackageList, eventsLog OrderedVodLogList, errors []ErrorLogEntry) (max TimepointType, avg int, total int) {
+	if combinedOutput {
+		sort.Stable(eventsLog)
+		printCombinedOutput(packages, eventsPerSecondList(packages), eventsLog, errors)
+	} else if !runConfig.eventSequenceLog {
+		printOutputFile(packages)
+	}
+
+	max, avg, total = printEventsPerSecond(packages, "eventsPerSecond")
+	if !combinedOutput {
+		if eventsPerSecondByMso {
+			for mso, msoPackages := range packagesByMso(packages) {
+				printEventsPerSecond(msoPackages, "eventsPerSecond-"+mso)
+			}
+		}
+		if segment != "" {
+			printEventsPerSecondBySegment(packages, segment)
+		}
+		if runConfig.vodLog {
+			printVodLogEntries(eventsLog)
+		} else if runConfig.eventSequenceLog {
+			printAllEvents(eventsLog)
+		}
+
+		printErrorLogs(errors)
+	}
+	return
 }
 
 var (
@@ -528,28 +1335,25 @@ func ensureFileName() string {
 func printAllEvents(eventsLog OrderedVodLogList) {
 
 	if len(eventsLog) == 0 {
-		fmt.Println("No events")
+		logInfo("No events")
 	} else {
 		mutex.Lock()
-		sort.Sort(eventsLog)
+		sort.Stable(eventsLog)
 		mutex.Unlock()
 		// Now save this to a a single events log file
 
 		filename := ensureFileName()
 
-		file, err := os.Create(filename)
+		w, err := createRollingOutputFile(filename)
 		if err != nil {
-			fmt.Println(err)
+			logError("%v", err)
 		}
 
-		w := bufio.NewWriter(file)
 		for _, event := range eventsLog {
-			fmt.Fprintf(w, "%v, %v, %v, %v, %v\n",
-				event.timestamp, event.received, event.deviceId, event.eventcode, event.mso)
+			fmt.Fprintln(w, event)
 		}
 		// Closing the file
-		w.Flush()
-		file.Close()
+		w.Close()
 	}
 
 }
@@ -557,41 +1361,15 @@ func printAllEvents(eventsLog OrderedVodLogList) {
 func printVodLogEntries(vodLog OrderedVodLogList) {
 
 	if len(vodLog) == 0 {
-		fmt.Println("No VOD events")
+		logInfo("No VOD events")
 	} else {
-		sort.Sort(vodLog)
-		// Now save this to a vod log file
-		// This is going to be the first file name
-		currentYear, currentMonth, currentDay := vodLog[0].timestamp.Date()
-
-		file, err := os.Create(formateCurrentFileName("vodLog", currentYear, currentMonth, currentDay))
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		w := bufio.NewWriter(file)
+		sort.Stable(vodLog)
+		// Now save this to the vod log file of every day
+		days := newDayFiles("vodLog")
 		for _, vodEntry := range vodLog {
-
-			if !validateFileDate(currentYear, currentMonth, currentDay, vodEntry.timestamp) {
-				// Close current file, open a new file - new date
-				w.Flush()
-				file.Close()
-
-				currentYear, currentMonth, currentDay = vodEntry.timestamp.Date()
-
-				file, err = os.Create(formateCurrentFileName("vodLog", currentYear, currentMonth, currentDay))
-				if err != nil {
-					fmt.Println(err)
-				}
-				w = bufio.NewWriter(file)
-			}
-
-			fmt.Fprintf(w, "%v, %v, %v, %v, %v\n",
-				vodEntry.timestamp, vodEntry.received, vodEntry.deviceId, vodEntry.eventcode, vodEntry.mso)
+			fmt.Fprintln(days.writer(vodEntry.timestamp), vodEntry)
 		}
-		// Closing the last file
-		w.Flush()
-		file.Close()
+		days.Close()
 	}
 
 }
@@ -606,8 +1384,15 @@ func (list OrderedVodLogList) Swap(i, j int) {
 	list[i], list[j] = list[j], list[i]
 }
 
+// Same tie-breakers as PackageList
 func (list OrderedVodLogList) Less(i, j int) bool {
-	return list[i].timestamp.Before(list[j].timestamp)
+	if !list[i].timestamp.Equal(list[j].timestamp) {
+		return list[i].timestamp.Before(list[j].timestamp)
+	}
+	if list[i].deviceId != list[j].deviceId {
+		return list[i].deviceId < list[j].deviceId
+	}
+	return list[i].eventcode < list[j].eventcode
 }
 
 // Single Clickstream package "sending"
@@ -617,7 +1402,7 @@ type TimepointType struct {
 }
 
 func (tp TimepointType) String() string {
-	return fmt.Sprintf("%v, %s", tp.timestamp, tp.numberOfEvents)
+	return fmt.Sprintf("%v, %d", tp.timestamp, tp.numberOfEvents)
 }
 
 type TimepointTypeList []TimepointType
@@ -634,7 +1419,8 @@ func (list TimepointTypeList) Less(i, j int) bool {
 	return list[i].timestamp.Before(list[j].timestamp)
 }
 
-func printEventsPerSecond(packages PackageList) (max TimepointType, avg int, total int) {
+// Packages per second, ordered by time
+func eventsPerSecondList(packages PackageList) TimepointTypeList {
 	eventsPerSecond := make(map[time.Time]int)
 
 	for _, pkg := range packages {
@@ -675,16 +1461,38 @@ func printEventsPerSecond(packages PackageList) (max TimepointType, avg int, tot
 	}
 
 	sort.Sort(orderedEventsPerSecond)
+	return orderedEventsPerSecond
+}
+
+// Rate of the packages per second, over the seconds with any packages sent and over the
+// wall clock span from the first of them to the last one, the idle seconds included
+func averagesPerSecond(orderedEventsPerSecond TimepointTypeList) (active, overSpan float64, span time.Duration) {
+	if len(orderedEventsPerSecond) == 0 {
+		return
+	}
+	events := 0
+	for _, points := range orderedEventsPerSecond {
+		events += points.numberOfEvents
+	}
+	span = orderedEventsPerSecond[len(orderedEventsPerSecond)-1].timestamp.Sub(orderedEventsPerSecond[0].timestamp) + time.Second
+	active = float64(events) / float64(len(orderedEventsPerSecond))
+	overSpan = float64(events) / span.Seconds()
+	return
+}
 
+func printEventsPerSecond(packages PackageList, filePrefix string) (max TimepointType, avg int, total int) {
+	return printTimepoints(eventsPerSecondList(packages), filePrefix)
+}
+
+// Writes the per second counts into the daily filePrefix-YYYY-MM-DD.csv files
+func printTimepoints(orderedEventsPerSecond TimepointTypeList, filePrefix string) (max TimepointType, avg int, total int) {
 	if len(orderedEventsPerSecond) == 0 {
 		// Nothing to print
-		if diagnostics {
-			fmt.Println("No events were found for primetime")
-		}
+		logDebug("No events were found for primetime")
 		return
 	}
 
-	if eventSequenceLogOnly {
+	if runConfig.eventSequenceLog || combinedOutput {
 		for _, points := range orderedEventsPerSecond {
 			if points.numberOfEvents > max.numberOfEvents {
 				max = points
@@ -693,41 +1501,29 @@ func printEventsPerSecond(packages PackageList) (max TimepointType, avg int, tot
 		}
 
 	} else {
-		// This is going to be the first file name
-		currentYear, currentMonth, currentDay := orderedEventsPerSecond[0].timestamp.Date()
-
-		file, err := os.Create(formateCurrentFileName("eventsPerSecond", currentYear, currentMonth, currentDay))
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		w := bufio.NewWriter(file)
+		days := newDayFiles(filePrefix)
+		var previous time.Time
+		var previousFile *OutputFile
 		for _, points := range orderedEventsPerSecond {
-
-			if !validateFileDate(currentYear, currentMonth, currentDay, points.timestamp) {
-				// Close current file, open a new file - new date
-				w.Flush()
-				file.Close()
-
-				currentYear, currentMonth, currentDay = points.timestamp.Date()
-
-				file, err = os.Create(formateCurrentFileName("eventsPerSecond", currentYear, currentMonth, currentDay))
-				if err != nil {
-					fmt.Println(err)
-				}
-				w = bufio.NewWriter(file)
+			w := days.writer(points.timestamp)
+			if w != previousFile {
+				// The gaps are filled within a day
+				previous = time.Time{}
+				previousFile = w
 			}
 
-			fmt.Fprintf(w, "%v, %d\n", points.timestamp, points.numberOfEvents)
+			if fillGaps && !previous.IsZero() {
+				writeZeroSeconds(w, previous, points.timestamp)
+			}
+			previous = points.timestamp
+			fmt.Fprintf(w, "%s, %d\n", timepointKey(points.timestamp), points.numberOfEvents)
 			if points.numberOfEvents > max.numberOfEvents {
 				max = points
 			}
 
 			avg += points.numberOfEvents
 		}
-		// Closing the last file
-		w.Flush()
-		file.Close()
+		days.Close()
 	}
 
 	if len(orderedEventsPerSecond) > 0 {
@@ -739,6 +1535,36 @@ func printEventsPerSecond(packages PackageList) (max TimepointType, avg int, tot
 	return
 }
 
+const (
+	epsKeyTime        = "time"
+	epsKeySecondOfDay = "sod"
+	epsKeyEpoch       = "epoch"
+)
+
+// Events per second bucket as written by -eps-key, plotting tools take the numbers easier
+func timepointKey(timestamp time.Time) string {
+	switch epsKey {
+	case epsKeySecondOfDay:
+		hour, min, sec := timestamp.Clock()
+		return strconv.Itoa(hour*3600 + min*60 + sec)
+	case epsKeyEpoch:
+		return strconv.FormatInt(timestamp.Unix(), 10)
+	}
+	return fmt.Sprint(timestamp)
+}
+
+// Zero rows for the seconds without events between two seconds of the same day,
+// gaps longer than -max-gap are left as they are
+func writeZeroSeconds(w io.Writer, from, to time.Time) {
+	gap := to.Sub(from) - time.Second
+	if gap <= 0 || (maxGap > 0 && gap > maxGap) {
+		return
+	}
+	for timestamp := from.Add(time.Second); timestamp.Before(to); timestamp = timestamp.Add(time.Second) {
+		fmt.Fprintf(w, "%s, 0\n", timepointKey(timestamp))
+	}
+}
+
 // Drops the date part, make everything time of 01/01/2016
 func unifiedTimeStamp(timestamp time.Time) time.Time {
 	hour, min, sec := timestamp.Clock()
@@ -747,18 +1573,13 @@ func unifiedTimeStamp(timestamp time.Time) time.Time {
 	return unifiedDateTime
 }
 
-// Compare current date and the date for the next event's timestamp
-func validateFileDate(currentYear int, currentMonth time.Month, currentDay int, timestamp time.Time) bool {
-	year, month, day := timestamp.Date()
-	return (year == currentYear && month == currentMonth && day == currentDay)
-}
-
-// filename for the current date
+// filename for the current date, the single one for all the dates with -flatten
 func formateCurrentFileName(fileprefix string, currentYear int, currentMoth time.Month, currentDay int) string {
-	fileName := fmt.Sprintf("%s-%04d-%02d-%02d.csv", fileprefix, currentYear, int(currentMoth), currentDay)
-	if diagnostics {
-		fmt.Println("New filename: ", fileName)
+	if flatten {
+		return fmt.Sprintf("%s-%s.csv", fileprefix, flattenName)
 	}
+	fileName := fmt.Sprintf("%s-%04d-%02d-%02d.csv", fileprefix, currentYear, int(currentMoth), currentDay)
+	logDebug("New filename: %s", fileName)
 	return fileName
 }
 
@@ -767,48 +1588,194 @@ func getFilesToProcess() []string {
 	fileList := []string{}
 	singleFileMode = false
 
-	if dirName == "" {
+	if dirName == "" && manifestFileName == "" && globPattern == "" {
 		if inFileName != "" {
 			// no Dir name provided, but file name provided =>
 			// Single file mode
 			singleFileMode = true
-			fileList = append(fileList, inFileName)
+			fileList = limitFiles(append(fileList, expandArchive(inFileName)...))
+			streamTarEntries(fileList)
 			return fileList
 		} else {
 			// no Dir name, no file name
-			fmt.Println("Input file name or working directory is not provided")
+			logError("Input file name or working directory is not provided")
 			usage()
 		}
 	}
 
-	// We have working directory - takes over single file name, if both provided
-	err := filepath.Walk(dirName, func(path string, f os.FileInfo, _ error) error {
-		if isRawFile(path) {
+	if manifestFileName != "" {
+		// Manifest files go first, in the manifest order
+		for _, path := range readManifest(manifestFileName) {
+			if !isMsoSelected(path) {
+				msoSkippedFiles++
+				logDebug("Skipped by MSO filter: %s", path)
+				continue
+			}
 			fileList = append(fileList, path)
-			if diagnostics {
-				fmt.Println("Added: ", path)
+		}
+	}
+
+	if dirName != "" {
+		fileList = append(fileList, walkDir(dirName)...)
+	}
+
+	if globPattern != "" {
+		matches, err := globFiles(globPattern)
+		if err != nil {
+			logError("Wrong glob pattern %s: %v", globPattern, err)
+			exit(-1)
+		}
+		if len(matches) == 0 {
+			logWarn("No files match %s", globPattern)
+		}
+		fileList = append(fileList, matches...)
+	}
+
+	if stateFileName != "" {
+		processingState = loadState(stateFileName, resetState)
+		fileList = processingState.filterProcessed(fileList)
+	}
+	fileList = limitFiles(fileList)
+	streamTarEntries(fileList)
+
+	fileCollisions = findFileCollisions(fileList)
+	for key, paths := range fileCollisions {
+		logWarn("MSO and date %s found in multiple files: %s", key, strings.Join(paths, ", "))
+	}
+	if strict && len(fileCollisions) > 0 {
+		logError("Duplicate MSO and date input files found in strict mode, aborting")
+		exit(-1)
+	}
+
+	if debugEnabled() {
+		for _, path := range fileList {
+			logDebug("%s", path)
+		}
+	}
+	return fileList
+}
+
+// The first -max-files of the files, the others are counted as left out
+func limitFiles(fileList []string) []string {
+	if maxFiles == 0 || len(fileList) <= maxFiles {
+		return fileList
+	}
+	filesOverLimit = len(fileList) - maxFiles
+	logWarn("Processing %d of the %d files found, -max-files", maxFiles, len(fileList))
+	return fileList[:maxFiles]
+}
+
+// We have working directory - takes over single file name, if both provided
+func walkDir(dirName string) []string {
+	fileList := []string{}
+	infos := make(map[string]os.FileInfo)
+	err := filepath.Walk(dirName, func(path string, f os.FileInfo, _ error) error {
+		inputs := []string{path}
+		if f != nil && !f.IsDir() && isArchive(path) {
+			var err error
+			if inputs, err = archiveEntries(path, infos); err != nil {
+				logWarn("Error reading archive %s: %v", path, err)
+				return nil
+			}
+		} else if isRawFile(path) {
+			infos[path] = f
+		} else {
+			return nil
+		}
+		for _, input := range inputs {
+			if !isMsoSelected(input) {
+				msoSkippedFiles++
+				logDebug("Skipped by MSO filter: %s", input)
+				continue
 			}
+			fileList = append(fileList, input)
+			logDebug("Added: %s", input)
 		}
 		return nil
 	})
 
 	if err != nil {
-		fmt.Println("Error getting files list: ", err)
-		os.Exit(-1)
+		logError("Error getting files list: %v", err)
+		exit(-1)
 	}
 
-	sort.Strings(fileList)
-	if diagnostics {
-		for _, path := range fileList {
-			fmt.Println(path)
+	sortFiles(fileList, infos)
+	return fileList
+}
+
+// Files of the same MSO and date, in different subfolders or under different names,
+// would be merged into the same per-day output files
+var fileCollisions map[string][]string
+
+// MSO and date of the input file name, e.g. "MSO1 2016-03-01" for clicks_20160301_MSO1.raw.
+// Without a date in the name it is the MSO and the base name.
+func collisionKey(path string) string {
+	name := inputBaseName(path)
+	for _, token := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '.' }) {
+		for _, layout := range []string{"20060102", minDateLayout} {
+			if date, err := time.Parse(layout, token); err == nil {
+				return msoName(path) + " " + date.Format(minDateLayout)
+			}
 		}
 	}
-	return fileList
+	return msoName(path) + " " + name
 }
 
-func isRawFile(fileName string) bool {
-	if diagnostics {
-		fmt.Printf("Ext: %s\tVerifying file:%s\n", inExtension, fileName)
+func findFileCollisions(fileList []string) map[string][]string {
+	byKey := make(map[string][]string)
+	for _, path := range fileList {
+		key := collisionKey(path)
+		byKey[key] = append(byKey[key], path)
+	}
+
+	collisions := make(map[string][]string)
+	for key, paths := range byKey {
+		if len(paths) > 1 {
+			collisions[key] = paths
+		}
+	}
+	return collisions
+}
+
+func printFileCollisions() {
+	if len(fileCollisions) == 0 {
+		return
+	}
+	fmt.Println("Duplicate MSO and dates: ", len(fileCollisions))
+	keys := make([]string, 0, len(fileCollisions))
+	for key := range fileCollisions {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("\t%s: %s\n", key, strings.Join(fileCollisions[key], ", "))
+	}
+}
+
+var msoSkippedFiles int
+
+func parseMsoFilter(list string) map[string]bool {
+	if list == "" {
+		return nil
+	}
+	filter := make(map[string]bool)
+	for _, mso := range strings.Split(list, ",") {
+		if mso = strings.TrimSpace(mso); mso != "" {
+			filter[mso] = true
+		}
+	}
+	return filter
+}
+
+// No filter means every MSO is selected
+func isMsoSelected(fileName string) bool {
+	if len(msoFilter) == 0 {
+		return true
+	}
+	return msoFilter[msoName(fileName)]
+}
+
+func isRawFile(fileName string) bool {
+	logDebug("Ext: %s\tVerifying file:%s", inExtension, fileName)
 	return filepath.Ext(fileName) == "."+inExtension
 }
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollisionKey(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"a/clicks_20160301_MSO1.raw", "MSO1 2016-03-01"},
+		{"b/stb_2016-03-01_MSO1.raw", "MSO1 2016-03-01"},
+		{"a/clicks_MSO1.raw", "MSO1 clicks_MSO1.raw"},
+		// Not a date, the digits of an id
+		{"a/clicks_20161301_MSO1.raw", "MSO1 clicks_20161301_MSO1.raw"},
+		{"data.zip!x/clicks_20160302_MSO2.raw", "MSO2 2016-03-02"},
+	}
+	for _, test := range tests {
+		if got := collisionKey(test.path); got != test.want {
+			t.Errorf("collisionKey(%s) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestFindFileCollisions(t *testing.T) {
+	files := []string{
+		"east/clicks_20160301_MSO1.raw",
+		"west/stb_20160301_MSO1.raw",
+		"west/clicks_20160301_MSO2.raw",
+		"east/clicks_20160302_MSO1.raw",
+		"east/all_MSO3.raw",
+		"west/all_MSO3.raw",
+	}
+	want := map[string][]string{
+		"MSO1 2016-03-01":   {"east/clicks_20160301_MSO1.raw", "west/stb_20160301_MSO1.raw"},
+		"MSO3 all_MSO3.raw": {"east/all_MSO3.raw", "west/all_MSO3.raw"},
+	}
+	if got := findFileCollisions(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("findFileCollisions = %v, want %v", got, want)
+	}
+}
+
+func TestConvertToTime(t *testing.T) {
+	tests := []struct {
+		timestamp string
+		base      int
+		want      time.Time
+		err       error
+	}{
+		{"00000000", 16, time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC), nil},
+		{"3C6A2E80", 16, time.Date(2012, 2, 18, 9, 14, 40, 0, time.UTC), nil},
+		{"4A4B4C4D", 16, time.Date(2019, 7, 6, 11, 45, 17, 0, time.UTC), nil},
+		{"00000010", 10, time.Date(1980, 1, 6, 0, 0, 10, 0, time.UTC), nil},
+		{"0000000A", 10, time.Time{}, errWrongTimestamp},
+		{"ZZZZZZZZ", 16, time.Time{}, errWrongTimestamp},
+		{"", 16, time.Time{}, errWrongTimestamp},
+	}
+	for _, test := range tests {
+		got, err := convertToTime(test.timestamp, test.base)
+		if !errors.Is(err, test.err) {
+			t.Errorf("convertToTime(%q, %d) error = %v, want %v", test.timestamp, test.base, err, test.err)
+			continue
+		}
+		if err == nil && !got.Equal(test.want) {
+			t.Errorf("convertToTime(%q, %d) = %v, want %v", test.timestamp, test.base, got.UTC(), test.want)
+		}
+	}
+}
+
+// The decode failure is an error of the line, not a zero time
+func TestParseEventWrongTimestamp(t *testing.T) {
+	source := LineSource{"MSO1", "a_MSO1.raw", 1}
+	_, _, _, _, _, err := parseEvent(testConfig(), "0000000001 50ZZZZ0000", nil, source, time.Now())
+	if !errors.Is(err, errWrongTimestamp) {
+		t.Errorf("parseEvent of a wrong timestamp = %v, want %v", err, errWrongTimestamp)
+	}
+}
+
+func TestMsoName(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{"clicks_MSO1.raw", "MSO1"},
+		{"dir/a_b_c.raw", "c"},
+		{"foo.raw", unknownMso},
+		{"noext_MSO", "MSO"},
+		{"a.b_raw", unknownMso},
+		{"trailing_.raw", unknownMso},
+		{"x_", unknownMso},
+		{"dir_MSO2/file.raw", unknownMso},
+		{"data.zip!x/clicks_MSO3.raw", "MSO3"},
+	}
+	for _, test := range tests {
+		if got := msoName(test.fileName); got != test.want {
+			t.Errorf("msoName(%q) = %q, want %q", test.fileName, got, test.want)
+		}
+	}
+}
+
+func TestParseEventDateRange(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	cfg := testConfig()
+	cfg.futureSkew = 0
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		err       error
+	}{
+		{"in range", now.Add(-time.Hour), nil},
+		{"at now", now, nil},
+		{"in the future", now.Add(time.Second), errWrongDate},
+		{"at -min-date", cfg.minDate, nil},
+		{"before -min-date", cfg.minDate.Add(-time.Second), errWrongDate},
+		{"GPS epoch", time.Unix(UTC_GPS_Diff, 0), errWrongDate},
+	}
+	for _, test := range tests {
+		line := rawLine(t, "dev1", "50", test.timestamp, "")
+		_, _, _, _, _, err := parseEvent(cfg, line, nil, LineSource{"MSO1", "a_MSO1.raw", 1}, now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: parseEvent(%s) = %v, want %v", test.name, line, err, test.err)
+		}
+	}
+}
+
+// The STB clocks a little ahead are within -future-skew
+func TestParseEventFutureSkew(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	cfg := testConfig()
+	cfg.futureSkew = 5 * time.Minute
+	tests := []struct {
+		ahead time.Duration
+		err   error
+	}{
+		{3 * time.Second, nil},
+		{5 * time.Minute, nil},
+		{5*time.Minute + time.Second, errWrongDate},
+	}
+	for _, test := range tests {
+		line := rawLine(t, "dev1", "50", now.Add(test.ahead), "")
+		timestamp, _, _, _, _, err := parseEvent(cfg, line, nil, LineSource{"MSO1", "a_MSO1.raw", 1}, now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v ahead: parseEvent = %v, want %v", test.ahead, err, test.err)
+		}
+		if err == nil && !timestamp.Equal(now.Add(test.ahead)) {
+			t.Errorf("%v ahead: timestamp %v, want %v", test.ahead, timestamp, now.Add(test.ahead))
+		}
+	}
+}
+
+// Same second packages by the device and the event, the insertion order of the rest kept
+func TestPackageListStableOrder(t *testing.T) {
+	second := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	packages := PackageList{
+		{second.Add(time.Second), "dev1", "Pulse", "MSO1"},
+		{second, "dev2", "Pulse", "MSO1"},
+		{second, "dev1", "Pulse", "MSO2"},
+		{second, "dev1", "Lock", "MSO1"},
+		{second, "dev1", "Pulse", "MSO1"},
+	}
+	want := PackageList{
+		{second, "dev1", "Lock", "MSO1"},
+		{second, "dev1", "Pulse", "MSO2"},
+		{second, "dev1", "Pulse", "MSO1"},
+		{second, "dev2", "Pulse", "MSO1"},
+		{second.Add(time.Second), "dev1", "Pulse", "MSO1"},
+	}
+	for run := 0; run < 3; run++ {
+		// Rotations that keep MSO2 ahead of MSO1 for the full tie
+		shuffled := append(PackageList{}, packages[run:]...)
+		shuffled = append(shuffled, packages[:run]...)
+		sort.Stable(shuffled)
+		if !reflect.DeepEqual(shuffled, want) {
+			t.Fatalf("sorted %v, want %v", shuffled, want)
+		}
+	}
+
+	events := OrderedVodLogList{
+		{timestamp: second, deviceId: "dev2", eventcode: "VOD Category"},
+		{timestamp: second, deviceId: "dev1", eventcode: "Info Screen", lineNo: 2},
+		{timestamp: second, deviceId: "dev1", eventcode: "Info Screen", lineNo: 1},
+	}
+	sort.Stable(events)
+	if events[0].lineNo != 2 || events[1].lineNo != 1 || events[2].deviceId != "dev2" {
+		t.Errorf("sorted events %v", events)
+	}
+}
+
+func TestTimepointKey(t *testing.T) {
+	saved := epsKey
+	defer func() { epsKey = saved }()
+
+	timestamp := time.Date(2016, 3, 1, 20, 0, 5, 0, time.UTC)
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{epsKeyTime, "2016-03-01 20:00:05 +0000 UTC"},
+		{epsKeySecondOfDay, "72005"},
+		{epsKeyEpoch, "1456862405"},
+	}
+	for _, test := range tests {
+		epsKey = test.key
+		if got := timepointKey(timestamp); got != test.want {
+			t.Errorf("-eps-key %s: %q, want %q", test.key, got, test.want)
+		}
+	}
+}
+
+// The daily file with the seconds since midnight in place of the time
+func TestEventsPerSecondSecondOfDay(t *testing.T) {
+	dir := withOutputDir(t)
+	saved := epsKey
+	defer func() { epsKey = saved }()
+	epsKey = epsKeySecondOfDay
+
+	start := time.Date(2016, 3, 1, 0, 1, 0, 0, time.UTC)
+	packages := PackageList{
+		Pack(start, "dev1", "Pulse", "MSO1"),
+		Pack(start, "dev2", "Pulse", "MSO1"),
+		Pack(start.Add(2*time.Second), "dev1", "Lock", "MSO1"),
+	}
+	printEventsPerSecond(packages, "eventsPerSecond")
+	if got, want := readOutput(t, dir, "eventsPerSecond-2016-03-01.csv"), "60, 2\n62, 1\n"; got != want {
+		t.Errorf("eventsPerSecond-2016-03-01.csv:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// The events sequence log entry carries the buffer size, the column only with -event-size
+func TestEventSizeColumn(t *testing.T) {
+	saved := eventSizeColumn
+	defer func() { eventSizeColumn = saved }()
+
+	cfg := testConfig()
+	cfg.eventSequenceLog = true
+	eventLogChan := make(chan EventLogEntry, 1)
+	timestamp := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	line := rawLine(t, "0000000001", "50", timestamp, "AABBCCDD")
+	_, _, _, eventSize, _, err := parseEvent(cfg, line, eventLogChan, LineSource{"MSO1", "size.raw", 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := <-eventLogChan
+	if entry.size != eventSize || eventSize == 0 {
+		t.Fatalf("entry size %d, parsed size %d", entry.size, eventSize)
+	}
+
+	eventSizeColumn = false
+	withoutSize := entry.String()
+	eventSizeColumn = true
+	if got, want := entry.String(), withoutSize+fmt.Sprintf(", %d", eventSize); got != want {
+		t.Errorf("-event-size entry %q, want %q", got, want)
+	}
+}
+
+// Zero rows inside a day, up to -max-gap, none over the midnight
+func TestEventsPerSecondFillGaps(t *testing.T) {
+	savedFill, savedGap := fillGaps, maxGap
+	defer func() { fillGaps, maxGap = savedFill, savedGap }()
+	fillGaps = true
+
+	start := time.Date(2016, 3, 1, 23, 59, 50, 0, time.UTC)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	packages := PackageList{
+		Pack(second(0), "dev1", "Pulse", "MSO1"),
+		Pack(second(3), "dev1", "Lock", "MSO1"),
+		Pack(second(9), "dev2", "Pulse", "MSO1"),
+		Pack(second(11), "dev2", "Lock", "MSO1"),
+	}
+
+	tests := []struct {
+		maxGap time.Duration
+		first  string
+	}{
+		{time.Hour, "59:50, 1\n59:51, 0\n59:52, 0\n59:53, 1\n59:54, 0\n59:55, 0\n59:56, 0\n59:57, 0\n59:58, 0\n59:59, 1\n"},
+		{3 * time.Second, "59:50, 1\n59:51, 0\n59:52, 0\n59:53, 1\n59:59, 1\n"},
+	}
+	for _, test := range tests {
+		dir := withOutputDir(t)
+		maxGap = test.maxGap
+		printEventsPerSecond(packages, "eventsPerSecond")
+		got := strings.ReplaceAll(readOutput(t, dir, "eventsPerSecond-2016-03-01.csv"), "2016-03-01 23:", "")
+		got = strings.ReplaceAll(got, " +0000 UTC", "")
+		if got != test.first {
+			t.Errorf("-max-gap %v, the first day:\n%s\nwant:\n%s", test.maxGap, got, test.first)
+		}
+		if got, want := readOutput(t, dir, "eventsPerSecond-2016-03-02.csv"), "2016-03-02 00:00:01 +0000 UTC, 1\n"; got != want {
+			t.Errorf("-max-gap %v, the next day:\n%s\nwant:\n%s", test.maxGap, got, want)
+		}
+	}
+}
+
+// The byte order mark of the first line and the \r of the CRLF endings are dropped
+func TestLineScannerBomCrlf(t *testing.T) {
+	input := utf8Bom + "first line\r\nsecond\r\n" + utf8Bom + "kept\r\nlast"
+	scanner := newLineScanner(strings.NewReader(input))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	want := []string{"first line", "second", utf8Bom + "kept", "last"}
+	if !reflect.DeepEqual(lines, want) || scanner.Err() != nil {
+		t.Errorf("lines %q %v, want %q", lines, scanner.Err(), want)
+	}
+}
+
+// Three days in the single -flatten file, no daily files
+func TestEventsPerSecondFlatten(t *testing.T) {
+	dir := withOutputDir(t)
+	savedFlatten, savedName := flatten, flattenName
+	defer func() { flatten, flattenName = savedFlatten, savedName }()
+	flatten, flattenName = true, "march"
+
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	packages := PackageList{
+		Pack(start, "dev1", "Pulse", "MSO1"),
+		Pack(start.AddDate(0, 0, 1), "dev1", "Pulse", "MSO1"),
+		Pack(start.AddDate(0, 0, 2), "dev2", "Pulse", "MSO1"),
+	}
+	printEventsPerSecond(packages, "eventsPerSecond")
+	want := "2016-03-01 20:00:00 +0000 UTC, 1\n2016-03-02 20:00:00 +0000 UTC, 1\n2016-03-03 20:00:00 +0000 UTC, 1\n"
+	if got := readOutput(t, dir, "eventsPerSecond-march.csv"); got != want {
+		t.Errorf("eventsPerSecond-march.csv:\n%s\nwant:\n%s", got, want)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files written, want the one -flatten file", len(entries))
+	}
+}
+
+func TestAveragesPerSecond(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	tests := []struct {
+		name     string
+		points   TimepointTypeList
+		active   float64
+		overSpan float64
+		span     time.Duration
+	}{
+		// 12 packages in 3 active seconds of the 8 from the first to the last
+		{"idle seconds", TimepointTypeList{{second(0), 2}, {second(3), 4}, {second(7), 6}}, 4, 1.5, 8 * time.Second},
+		{"no idle seconds", TimepointTypeList{{second(0), 3}, {second(1), 5}}, 4, 4, 2 * time.Second},
+		{"one second", TimepointTypeList{{second(0), 5}}, 5, 5, time.Second},
+		{"none", nil, 0, 0, 0},
+	}
+	for _, test := range tests {
+		active, overSpan, span := averagesPerSecond(test.points)
+		if active != test.active || overSpan != test.overSpan || span != test.span {
+			t.Errorf("%s: %v active, %v over the %v span, want %v, %v and %v",
+				test.name, active, overSpan, span, test.active, test.overSpan, test.span)
+		}
+	}
+}
+
+// The same line layout with the -ts-base 16 and the -ts-base 10 digits
+func TestParseEventTimestampBase(t *testing.T) {
+	source := LineSource{"MSO1", "a_MSO1.raw", 1}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		base   int
+		bytes  int
+		digits string
+		want   time.Time
+		err    error
+	}{
+		{16, 4, "3C6A2E80", time.Date(2012, 2, 18, 9, 14, 40, 0, time.UTC), nil},
+		{10, 5, "1013000000", time.Date(1980, 1, 6, 0, 0, 1013000000, 0, time.UTC), nil},
+		{10, 5, "0000000060", time.Date(1980, 1, 6, 0, 1, 0, 0, time.UTC), errWrongDate},
+		// Hex digits are no decimal timestamp, the base is not guessed
+		{10, 4, "3C6A2E80", time.Time{}, errWrongTimestamp},
+	}
+	for _, test := range tests {
+		cfg := testConfig()
+		cfg.timestampBase = test.base
+		cfg.timestampBytes = test.bytes
+		timestamp, _, _, _, _, err := parseEvent(cfg, "0000000001 50"+test.digits+"AB", nil, source, now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("-ts-base %d %s: error %v, want %v", test.base, test.digits, err, test.err)
+			continue
+		}
+		if test.err != errWrongTimestamp && !timestamp.Equal(test.want) {
+			t.Errorf("-ts-base %d %s: %v, want %v", test.base, test.digits, timestamp.UTC(), test.want)
+		}
+	}
+}
+
+// The oldest two of the directory with -order mtime, the newest two with mtime-desc,
+// the rest counted as left out
+func TestGetFilesToProcessMaxFiles(t *testing.T) {
+	savedDir, savedMax, savedOrder, savedOver := dirName, maxFiles, fileOrder, filesOverLimit
+	defer func() { dirName, maxFiles, fileOrder, filesOverLimit = savedDir, savedMax, savedOrder, savedOver }()
+
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	for i, name := range []string{"a_MSO1.raw", "b_MSO1.raw", "c_MSO1.raw", "d_MSO1.raw"} {
+		path := writeInput(t, dir, name, rawLine(t, "dev1", "50", start, ""))
+		// Named in the reverse of the mtime order
+		modTime := start.Add(time.Duration(4-i) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirName, maxFiles, fileOrder, filesOverLimit = dir, 2, orderMtime, 0
+
+	files := getFilesToProcess()
+	want := []string{filepath.Join(dir, "d_MSO1.raw"), filepath.Join(dir, "c_MSO1.raw")}
+	if !reflect.DeepEqual(files, want) || filesOverLimit != 2 {
+		t.Errorf("-max-files 2: %v, %d left out, want %v and 2", files, filesOverLimit, want)
+	}
+
+	_, result := processPackages(t, testConfig(), files)
+	if result.files != 2 {
+		t.Errorf("%d files processed, want 2", result.files)
+	}
+
+	fileOrder, filesOverLimit = orderMtimeDesc, 0
+	files = getFilesToProcess()
+	want = []string{filepath.Join(dir, "a_MSO1.raw"), filepath.Join(dir, "b_MSO1.raw")}
+	if !reflect.DeepEqual(files, want) || filesOverLimit != 2 {
+		t.Errorf("-max-files 2 -order mtime-desc: %v, %d left out, want %v and 2", files, filesOverLimit, want)
+	}
+}
+
+// One framing byte before the event code: the code, the timestamp and the payload
+// offsets shift by it, the byte counts in the event size
+func TestParseEventCodeOffset(t *testing.T) {
+	source := LineSource{"MSO1", "a_MSO1.raw", 1}
+	line := "0000000001 FF493C6A2E8056"
+
+	cfg := testConfig()
+	cfg.strictCodes = true
+	if _, _, _, _, _, err := parseEvent(cfg, line, nil, source, time.Now()); !errors.Is(err, errUnknownCode) {
+		t.Errorf("without -code-offset: %v, want the FF code unknown", err)
+	}
+
+	cfg.codeOffset = 1
+	cfg.vodLog = true
+	eventLogChan := make(chan EventLogEntry, 1)
+	timestamp, _, _, eventSize, eventCode, err := parseEvent(cfg, line, eventLogChan, source, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2012, 2, 18, 9, 14, 40, 0, time.UTC)
+	if eventCode != "`I`Info Screen" || !timestamp.Equal(want) || eventSize != 7 {
+		t.Errorf("-code-offset 1: %s %v %d bytes, want `I`Info Screen %v 7 bytes", eventCode, timestamp.UTC(), eventSize, want)
+	}
+	if entry := <-eventLogChan; entry.eventcode != "`I`Info Screen / Type V" {
+		t.Errorf("-code-offset 1: VOD entry %q, want the type V", entry.eventcode)
+	}
+
+	cfg.codeOffset = 8
+	if _, _, _, _, _, err := parseEvent(cfg, line, nil, source, time.Now()); err != errWrongLineFormat {
+		t.Errorf("-code-offset past the clickstring: %v, want %v", err, errWrongLineFormat)
+	}
+}
+
+// Codes not in the commands list are errors with -strict-codes, kept as UNKNOWN-<hex>
+// and counted by the code otherwise
+func TestConvertToLogNameUnknown(t *testing.T) {
+	if name, err := convertToLogName("FF3C6A2E80", true); !errors.Is(err, errUnknownCode) {
+		t.Errorf("strict: %q, %v, want %v", name, err, errUnknownCode)
+	}
+	if name, err := convertToLogName("FF3C6A2E80", false); name != "UNKNOWN-FF" || err != nil {
+		t.Errorf("not strict: %q, %v, want UNKNOWN-FF", name, err)
+	}
+	if name, err := convertToLogName("503C6A2E80", true); name != "`P`Pulse" || err != nil {
+		t.Errorf("strict known code: %q, %v, want `P`Pulse", name, err)
+	}
+
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	unknownLine := func(deviceId string, timestamp time.Time, payload string) string {
+		return strings.Replace(rawLine(t, deviceId, "50", timestamp, payload), " 50", " FF", 1)
+	}
+	fileName := writeInput(t, t.TempDir(), "a_MSO1.raw",
+		unknownLine("dev1", start, ""),
+		rawLine(t, "dev1", "50", start.Add(time.Second), ""),
+		unknownLine("dev2", start.Add(2*time.Second), "0A"))
+	cfg := testConfig()
+	_, result := processPackages(t, cfg, []string{fileName})
+	if unknown := result.unknownCodes["FF"]; result.validEvents != 3 || len(result.errors) != 0 || unknown == nil || unknown.events != 2 {
+		t.Errorf("not strict: %d valid events, errors %v, unknown %+v, want 3 events and FF twice", result.validEvents, result.errors, unknown)
+	}
+	if codes := result.msoStats["MSO1"].codes; codes["UNKNOWN-FF"] != 2 {
+		t.Errorf("MSO1 codes %v, want UNKNOWN-FF twice", codes)
+	}
+
+	cfg.strictCodes = true
+	_, result = processPackages(t, cfg, []string{fileName})
+	if unknown := result.unknownCodes["FF"]; result.validEvents != 1 || len(result.errors) != 2 || unknown == nil || unknown.events != 2 {
+		t.Errorf("strict: %d valid events, %d errors, unknown %+v, want 1 event and FF twice", result.validEvents, len(result.errors), unknown)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -in-format values
+const (
+	rawInput = "raw"
+	csvInput = "csv"
+)
+
+// Timestamps as written by the csv outputs, RFC3339 is accepted too
+const csvTimeLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// Column indexes of an already parsed events csv, -1 for the optional ones not present
+type CsvColumns struct {
+	count     int
+	timestamp int
+	deviceId  int
+	eventCode int
+	eventSize int
+	received  int
+	mso       int
+}
+
+// The header names the columns: timestamp, deviceId and eventCode are required,
+// eventSize, received and mso are optional, the other columns are ignored.
+func parseCsvHeader(header string) (*CsvColumns, error) {
+	columns := &CsvColumns{timestamp: -1, deviceId: -1, eventCode: -1, eventSize: -1, received: -1, mso: -1}
+	names := splitCsvLine(header)
+	columns.count = len(names)
+	for i, name := range names {
+		var column *int
+		switch name {
+		case "timestamp":
+			column = &columns.timestamp
+		case "deviceId":
+			column = &columns.deviceId
+		case "eventCode":
+			column = &columns.eventCode
+		case "eventSize":
+			column = &columns.eventSize
+		case "received":
+			column = &columns.received
+		case "mso":
+			column = &columns.mso
+		default:
+			continue
+		}
+		if *column >= 0 {
+			return nil, fmt.Errorf("Duplicate column in the csv header: %s", name)
+		}
+		*column = i
+	}
+	if columns.timestamp < 0 || columns.deviceId < 0 || columns.eventCode < 0 {
+		return nil, fmt.Errorf("Wrong csv header [%s], expected timestamp, deviceId, eventCode[, eventSize]", header)
+	}
+	return columns, nil
+}
+
+func splitCsvLine(line string) []string {
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+func (columns *CsvColumns) deviceIdOf(line string) string {
+	fields := splitCsvLine(line)
+	if columns.deviceId >= len(fields) {
+		return ""
+	}
+	return fields[columns.deviceId]
+}
+
+func parseCsvTime(value string) (time.Time, error) {
+	if timestamp, err := time.Parse(csvTimeLayout, value); err == nil {
+		return timestamp, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// The parseEvent of the csv input: the event code is a hex code or an event name,
+// the size without the eventSize column is the fixed one from the codes config.
+// The VOD log needs the clickstrings, only the events sequence log is written.
+func parseCsvEvent(cfg *Config, line string, columns *CsvColumns, eventLogChan chan<- EventLogEntry, source LineSource, now time.Time) (timestamp time.Time, received string, deviceId string, eventSize int, eventCode string, err error) {
+	fields := splitCsvLine(line)
+	if len(fields) != columns.count {
+		return now, "", "", 0, "", errWrongLineFormat
+	}
+
+	timestamp, err = parseCsvTime(fields[columns.timestamp])
+	if err != nil {
+		return now, "", "", 0, "", fmt.Errorf("%w: %s", errWrongTimestamp, fields[columns.timestamp])
+	}
+	deviceId = cfg.anonymize(fields[columns.deviceId])
+	if deviceId == "" {
+		return now, "", "", 0, "", errWrongLineFormat
+	}
+	code, ok := findCode(fields[columns.eventCode])
+	if ok {
+		eventCode = eventNames[code]
+	} else if code, ok = unknownCodeOf(fields[columns.eventCode], cfg.strictCodes); ok {
+		eventCode = fields[columns.eventCode]
+	} else {
+		return now, "", "", 0, "", errUnknownCode
+	}
+
+	if columns.eventSize >= 0 {
+		eventSize, err = strconv.Atoi(fields[columns.eventSize])
+		if err != nil || eventSize < 0 {
+			return now, "", "", 0, "", fmt.Errorf("%w: %s", errWrongEventSize, fields[columns.eventSize])
+		}
+	} else if size, ok := eventSizes[code]; ok {
+		eventSize = size + cfg.sizeOverhead
+	} else {
+		return now, "", "", 0, "", fmt.Errorf("%w: no size for code %s", errWrongEventSize, code)
+	}
+
+	received = "1900-01-01 00:00:00"
+	if columns.received >= 0 {
+		received = fields[columns.received]
+	}
+	if columns.mso >= 0 && fields[columns.mso] != "" {
+		source.mso = fields[columns.mso]
+	}
+
+	if timestamp.After(now.Add(cfg.futureSkew)) || timestamp.Before(cfg.minDate) {
+		err = fmt.Errorf("%w: %v", errWrongDate, timestamp)
+	}
+	if cfg.eventSequenceLog {
+		eventLogChan <- EventLogEntry{timestamp, received, deviceId, eventCode, source.mso, eventSize, "", source.fileName, source.lineNo}
+	}
+	return
+}
+
+// Where an input line comes from: the MSO of the events and the -trace-source columns
+type LineSource struct {
+	mso      string
+	fileName string
+	lineNo   int
+}
+
+// Hex code of an UNKNOWN-<hex> event name, not accepted with -strict-codes
+func unknownCodeOf(name string, strict bool) (string, bool) {
+	if strict || !strings.HasPrefix(name, unknownCodePrefix) {
+		return "", false
+	}
+	code := strings.TrimPrefix(name, unknownCodePrefix)
+	_, ok := decodeHexByte(code)
+	return code, ok && len(code) == 2
+}
+
+// parseEvent or parseCsvEvent, by the input format of the file
+func parseInputEvent(cfg *Config, line string, columns *CsvColumns, eventLogChan chan<- EventLogEntry, source LineSource, now time.Time) (time.Time, string, string, int, string, error) {
+	if columns != nil {
+		return parseCsvEvent(cfg, line, columns, eventLogChan, source, now)
+	}
+	return parseEvent(cfg, line, eventLogChan, source, now)
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
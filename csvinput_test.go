@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Raw clickstrings to the events csv and back into the simulation, the same packages
+func TestCsvInputRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	raw := writeSpreadFiles(t, dir, 1, 10, 400)
+
+	cfg := testConfig()
+	cfg.eventSequenceLog = true
+	eventLogChan := make(chan EventLogEntry, 1000)
+	var events OrderedVodLogList
+	done := make(chan struct{})
+	go func() {
+		for entry := range eventLogChan {
+			events = append(events, entry)
+		}
+		close(done)
+	}()
+	result, err := Process(context.Background(), cfg, raw, eventLogChan, newBufferState(), time.Now())
+	close(eventLogChan)
+	<-done
+	if err != nil || len(result.packages) == 0 || len(events) != 400 {
+		t.Fatalf("raw: %d packages, %d events, %v", len(result.packages), len(events), err)
+	}
+	rawPackages := PackageList(result.packages)
+	sort.Stable(rawPackages)
+
+	lines := []string{"timestamp, deviceId, eventCode, eventSize"}
+	for _, event := range events {
+		lines = append(lines, fmt.Sprintf("%s, %s, %s, %d", event.timestamp.Format(csvTimeLayout), event.deviceId, event.eventcode, event.size))
+	}
+	csv := writeInput(t, dir, "events_MSO1.csv", lines...)
+	cfg = testConfig()
+	cfg.inputFormat = csvInput
+	csvPackages, csvResult := processPackages(t, cfg, []string{csv})
+	if csvResult.validEvents != 400 || len(csvResult.errors) != 0 {
+		t.Fatalf("csv: %d events, errors %v", csvResult.validEvents, csvResult.errors)
+	}
+	if len(csvPackages) != len(rawPackages) {
+		t.Fatalf("csv: %d packages, raw %d", len(csvPackages), len(rawPackages))
+	}
+	for i, pkg := range csvPackages {
+		// The csv timestamps are in UTC, the raw ones in the local time
+		want := rawPackages[i]
+		if !pkg.timestamp.Equal(want.timestamp) || pkg.deviceId != want.deviceId || pkg.eventCode != want.eventCode || pkg.mso != want.mso {
+			t.Errorf("csv package %d: %v, raw %v", i, pkg, want)
+		}
+	}
+}
+
+func TestParseCsvHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"timestamp, deviceId, eventCode", true},
+		{"mso,eventCode,extra,deviceId,timestamp,eventSize", true},
+		{"timestamp, deviceId", false},
+		{"timestamp, deviceId, eventCode, deviceId", false},
+	}
+	for _, test := range tests {
+		if _, err := parseCsvHeader(test.header); (err == nil) != test.ok {
+			t.Errorf("parseCsvHeader(%q) = %v, want ok %v", test.header, err, test.ok)
+		}
+	}
+}
+
+func TestParseCsvEventErrors(t *testing.T) {
+	columns, err := parseCsvHeader("timestamp, deviceId, eventCode, eventSize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		err  error
+	}{
+		{"2016-03-01T20:00:00Z, dev1, `P`Pulse, 12", nil},
+		{"2016-03-01 20:00:00 +0000 UTC, dev1, 50, 12", nil},
+		{"yesterday, dev1, 50, 12", errWrongTimestamp},
+		{"2016-03-01T20:00:00Z, dev1, No Such Event, 12", errUnknownCode},
+		{"2016-03-01T20:00:00Z, dev1, 50, -1", errWrongEventSize},
+		{"2016-03-01T20:00:00Z, dev1, 50", errWrongLineFormat},
+	}
+	for _, test := range tests {
+		_, _, _, _, _, err := parseCsvEvent(testConfig(), test.line, columns, nil, LineSource{"MSO1", "a_MSO1.csv", 2}, time.Now())
+		if !errors.Is(err, test.err) {
+			t.Errorf("parseCsvEvent(%q) = %v, want %v", test.line, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dbBatchSize = 10000
+	// Registered by the driver in sqlite_driver.go, build with -tags sqlite
+	sqliteDriverName = "sqlite"
+)
+
+const (
+	createEventsTable = `CREATE TABLE IF NOT EXISTS events (
+	run_id    TEXT NOT NULL,
+	timestamp DATETIME NOT NULL,
+	received  TEXT,
+	deviceId  TEXT NOT NULL,
+	eventCode TEXT NOT NULL,
+	mso       TEXT,
+	eventSize INTEGER
+)`
+	createPackagesTable = `CREATE TABLE IF NOT EXISTS packages (
+	run_id    TEXT NOT NULL,
+	timestamp DATETIME NOT NULL,
+	deviceId  TEXT NOT NULL,
+	eventCode TEXT NOT NULL,
+	mso       TEXT
+)`
+	insertEvent   = `INSERT INTO events (run_id, timestamp, received, deviceId, eventCode, mso, eventSize) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	insertPackage = `INSERT INTO packages (run_id, timestamp, deviceId, eventCode, mso) VALUES (?, ?, ?, ?, ?)`
+)
+
+// Writes parsed events and packages into a database in batched transactions.
+// Every run is tagged with its own run_id, so runs can be appended to the same database.
+// Safe for use by the concurrent file workers.
+type DbSink struct {
+	sync.Mutex
+	db      *sql.DB
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+	runId   string
+	err     error
+}
+
+var dbSink *DbSink
+
+func isDriverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// spec is driver:datasource, only sqlite is supported for now
+func openDbSink(spec string, runId string) (*DbSink, error) {
+	driverName, dataSource, ok := strings.Cut(spec, ":")
+	if !ok || dataSource == "" {
+		return nil, errors.New("Wrong database sink format, expected sqlite:path.db")
+	}
+	if driverName != sqliteDriverName {
+		return nil, errors.New("Unsupported database sink: " + driverName)
+	}
+	if !isDriverRegistered(driverName) {
+		return nil, errors.New("SQLite driver is not available, rebuild with -tags sqlite")
+	}
+
+	db, err := sql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	for _, schema := range []string{createEventsTable, createPackagesTable} {
+		if _, err = db.Exec(schema); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &DbSink{db: db, runId: runId}, nil
+}
+
+// Starts a new transaction with the prepared insert when there is none
+func (sink *DbSink) begin(query string) error {
+	if sink.tx != nil {
+		return nil
+	}
+	tx, err := sink.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	sink.tx, sink.stmt = tx, stmt
+	return nil
+}
+
+func (sink *DbSink) commit() error {
+	if sink.tx == nil {
+		return nil
+	}
+	sink.stmt.Close()
+	err := sink.tx.Commit()
+	sink.tx, sink.stmt, sink.pending = nil, nil, 0
+	return err
+}
+
+// The first error stops the sink, the rest of the run is not affected
+func (sink *DbSink) insert(query string, args ...interface{}) {
+	if sink.err != nil {
+		return
+	}
+	if err := sink.begin(query); err != nil {
+		sink.fail(err)
+		return
+	}
+	if _, err := sink.stmt.Exec(args...); err != nil {
+		sink.fail(err)
+		return
+	}
+	sink.pending++
+	if sink.pending >= dbBatchSize {
+		if err := sink.commit(); err != nil {
+			sink.fail(err)
+		}
+	}
+}
+
+func (sink *DbSink) fail(err error) {
+	logError("Database sink error: %v", err)
+	if sink.tx != nil {
+		sink.tx.Rollback()
+		sink.tx, sink.stmt = nil, nil
+	}
+	sink.err = err
+}
+
+func (sink *DbSink) addEvent(timestamp time.Time, received, deviceId, eventCode, mso string, eventSize int) {
+	sink.Lock()
+	defer sink.Unlock()
+	sink.insert(insertEvent, sink.runId, timestamp, received, deviceId, eventCode, mso, eventSize)
+}
+
+func (sink *DbSink) addPackages(packages PackageList) {
+	sink.Lock()
+	defer sink.Unlock()
+	if sink.err == nil {
+		// Finish the events batch, the packages use another statement
+		if err := sink.commit(); err != nil {
+			sink.fail(err)
+		}
+	}
+	for _, pkg := range packages {
+		sink.insert(insertPackage, sink.runId, pkg.timestamp, pkg.deviceId, pkg.eventCode, pkg.mso)
+	}
+}
+
+func (sink *DbSink) Close() error {
+	sink.Lock()
+	defer sink.Unlock()
+	err := sink.err
+	if err == nil {
+		err = sink.commit()
+	}
+	if closeErr := sink.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
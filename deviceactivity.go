@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const deviceActivityFileName = "deviceActivity.csv"
+
+// First and last valid event of a device, with -first-last
+type DeviceActivity struct {
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// Caller holds the buffers lock
+func (state *BufferState) addActivity(deviceId string, timestamp time.Time) {
+	activity, ok := state.activity[deviceId]
+	if !ok {
+		state.activity[deviceId] = &DeviceActivity{timestamp, timestamp}
+		return
+	}
+	// The files are not in the time order, neither are the devices across them
+	if timestamp.Before(activity.firstSeen) {
+		activity.firstSeen = timestamp
+	}
+	if timestamp.After(activity.lastSeen) {
+		activity.lastSeen = timestamp
+	}
+}
+
+// Sorted by the last event, the devices gone silent first
+func printDeviceActivity(buffers *BufferState) {
+	buffers.Lock()
+	defer buffers.Unlock()
+
+	devices := make([]string, 0, len(buffers.activity))
+	for deviceId := range buffers.activity {
+		devices = append(devices, deviceId)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		left, right := buffers.activity[devices[i]], buffers.activity[devices[j]]
+		if !left.lastSeen.Equal(right.lastSeen) {
+			return left.lastSeen.Before(right.lastSeen)
+		}
+		return devices[i] < devices[j]
+	})
+
+	w, err := createOutputFile(deviceActivityFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "deviceId, firstSeen, lastSeen, span")
+	for _, deviceId := range devices {
+		activity := buffers.activity[deviceId]
+		fmt.Fprintf(w, "%s, %v, %v, %v\n",
+			deviceId, activity.firstSeen, activity.lastSeen, activity.lastSeen.Sub(activity.firstSeen))
+	}
+	w.Close()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Devices active in different windows, spread over the files out of the time order
+func TestDeviceActivity(t *testing.T) {
+	dir := withOutputDir(t)
+	input := t.TempDir()
+	// The raw timestamps are read in the local time
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.Local)
+	minute := func(n int) time.Time { return start.Add(time.Duration(n) * time.Minute) }
+	files := []string{
+		writeInput(t, input, "a_MSO1.raw",
+			rawLine(t, "late", "50", minute(30), ""),
+			rawLine(t, "early", "50", minute(5), ""),
+			rawLine(t, "late", "50", minute(50), "")),
+		writeInput(t, input, "b_MSO1.raw",
+			rawLine(t, "early", "50", minute(0), ""),
+			rawLine(t, "all", "50", minute(0), ""),
+			rawLine(t, "all", "50", minute(60), ""),
+			rawLine(t, "early", "50", minute(10), "")),
+	}
+
+	cfg := testConfig()
+	cfg.deviceActivity = true
+	cfg.concurrency = 1
+	buffers := newBufferState()
+	if _, err := Process(context.Background(), cfg, files, nil, buffers, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	printDeviceActivity(buffers)
+
+	want := "deviceId, firstSeen, lastSeen, span\n" +
+		fmt.Sprintf("early, %v, %v, 10m0s\n", minute(0), minute(10)) +
+		fmt.Sprintf("late, %v, %v, 20m0s\n", minute(30), minute(50)) +
+		fmt.Sprintf("all, %v, %v, 1h0m0s\n", minute(0), minute(60))
+	if got := readOutput(t, dir, deviceActivityFileName); got != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", deviceActivityFileName, got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Raw clickstring of an event, the inverse of parseEvent: the zero -code-offset framing bytes,
+// the hex code, the GPS seconds in the timestamp width of the code (the codes config or -ts-bytes),
+// 2 hex digits a byte (or 2 decimal digits, -ts-base 10) and the hex payload
+func encodeEvent(cfg *Config, code string, timestamp time.Time, payload string) (string, error) {
+	if _, ok := eventNames[code]; !ok {
+		return "", errUnknownCode
+	}
+	digits := 2 * timestampWidth(code, cfg.timestampBytes)
+	seconds := timestamp.Unix() - UTC_GPS_Diff
+	maxSeconds, verb := uint64(1)<<(4*uint(digits))-1, "X"
+	if cfg.timestampBase == 10 {
+		maxSeconds, verb = 1, "d"
+		for i := 0; i < digits; i++ {
+			maxSeconds *= 10
+		}
+		maxSeconds--
+	}
+	if seconds < 0 || uint64(seconds) > maxSeconds {
+		return "", fmt.Errorf("Timestamp out of the GPS range: %v", timestamp)
+	}
+	if _, err := hex.DecodeString(payload); err != nil {
+		return "", fmt.Errorf("Wrong payload [%s]: %v", payload, err)
+	}
+	framing := strings.Repeat("00", cfg.codeOffset)
+	return fmt.Sprintf("%s%s%0*"+verb+"%s", framing, code, digits, seconds, strings.ToUpper(payload)), nil
+}
+
+// Reads the -encode spec csv with a timestamp, deviceId, eventCode[, payload][, received]
+// header and writes the raw lines, ready to be read back as a .raw input file.
+// Timestamps are as in the -in-format csv, codes are hex codes or event names.
+func encodeEvents(cfg *Config, specFileName string, w io.Writer) (int, error) {
+	file, err := os.Open(specFileName)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := newLineScanner(file)
+	if !scanner.Scan() {
+		return 0, fmt.Errorf("Empty encode spec %s", specFileName)
+	}
+	header := splitCsvLine(scanner.Text())
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"timestamp", "deviceId", "eventCode"} {
+		if _, ok := columns[name]; !ok {
+			return 0, fmt.Errorf("Wrong encode spec header in %s, no %s column", specFileName, name)
+		}
+	}
+	field := func(fields []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return fields[i]
+		}
+		return ""
+	}
+
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	lineNo, encoded := 1, 0
+	for scanner.Scan() {
+		lineNo++
+		fields := splitCsvLine(scanner.Text())
+		if len(fields) != len(header) {
+			return encoded, fmt.Errorf("%s:%d: wrong line format", specFileName, lineNo)
+		}
+		timestamp, err := parseCsvTime(field(fields, "timestamp"))
+		if err != nil {
+			return encoded, fmt.Errorf("%s:%d: wrong timestamp: %v", specFileName, lineNo, err)
+		}
+		code, ok := findCode(field(fields, "eventCode"))
+		if !ok {
+			return encoded, fmt.Errorf("%s:%d: unknown event code %s", specFileName, lineNo, field(fields, "eventCode"))
+		}
+		clickString, err := encodeEvent(cfg, code, timestamp, field(fields, "payload"))
+		if err != nil {
+			return encoded, fmt.Errorf("%s:%d: %v", specFileName, lineNo, err)
+		}
+		deviceId := field(fields, "deviceId")
+		if strings.ContainsRune(deviceId, ' ') {
+			return encoded, fmt.Errorf("%s:%d: wrong deviceId [%s], the raw tokens have no spaces", specFileName, lineNo, deviceId)
+		}
+		if received := field(fields, "received"); strings.ContainsRune(received, ' ') {
+			return encoded, fmt.Errorf("%s:%d: wrong received [%s], the raw tokens have no spaces", specFileName, lineNo, received)
+		} else if received != "" {
+			fmt.Fprintf(out, "%s %s %s\n", received, deviceId, clickString)
+		} else {
+			fmt.Fprintf(out, "%s %s\n", deviceId, clickString)
+		}
+		encoded++
+	}
+	return encoded, scanner.Err()
+}
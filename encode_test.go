@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The encoded events read back by the parser in the same settings
+func TestEncodeEventRoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 3, 5, 20, 15, 0, 0, time.UTC)
+	timestampWidths["49"] = 6
+	defer delete(timestampWidths, "49")
+
+	tests := []struct {
+		name           string
+		code           string
+		timestampBytes int
+		timestampBase  int
+		codeOffset     int
+		want           string
+	}{
+		{"default", "50", 4, 16, 0, "50"},
+		{"ts-bytes 5", "50", 5, 16, 0, "5000"},
+		{"ts-base 10, 5 bytes", "50", 5, 10, 0, "50"},
+		{"codes config width", "49", 4, 16, 0, "490000"},
+		{"code offset", "50", 4, 16, 1, "0050"},
+	}
+	for _, test := range tests {
+		cfg := testConfig()
+		cfg.timestampBytes = test.timestampBytes
+		cfg.timestampBase = test.timestampBase
+		cfg.codeOffset = test.codeOffset
+		clickString, err := encodeEvent(cfg, test.code, timestamp, "0A0B")
+		if err != nil {
+			t.Errorf("%s: encodeEvent: %v", test.name, err)
+			continue
+		}
+		if clickString[:len(test.want)] != test.want {
+			t.Errorf("%s: encodeEvent = %s, want the %s prefix", test.name, clickString, test.want)
+		}
+		source := LineSource{"MSO1", "encode.raw", 1}
+		parsed, _, deviceId, _, eventCode, err := parseEvent(cfg, "0000000001 "+clickString, nil, source, time.Now())
+		if err != nil {
+			t.Errorf("%s: parseEvent(%s): %v", test.name, clickString, err)
+			continue
+		}
+		if !parsed.Equal(timestamp) || deviceId != "0000000001" || eventCode != eventNames[test.code] {
+			t.Errorf("%s: parsed %v %s %s, want %v 0000000001 %s", test.name, parsed, deviceId, eventCode, timestamp, eventNames[test.code])
+		}
+	}
+}
+
+func TestEncodeEventRange(t *testing.T) {
+	cfg := testConfig()
+	// Past the 4 bytes of GPS seconds, in the range of 5
+	late := time.Unix(UTC_GPS_Diff+0x100000000, 0)
+	if _, err := encodeEvent(cfg, "50", late, ""); err == nil {
+		t.Error("encodeEvent past the 4 byte range, want an error")
+	}
+	cfg.timestampBytes = 5
+	if _, err := encodeEvent(cfg, "50", late, ""); err != nil {
+		t.Errorf("encodeEvent in the 5 byte range: %v", err)
+	}
+	if _, err := encodeEvent(cfg, "ZZ", late, ""); err != errUnknownCode {
+		t.Errorf("encodeEvent of an unknown code = %v, want %v", err, errUnknownCode)
+	}
+}
+
+// The -encode spec to raw lines, parsed back to the same events
+func TestEncodeEventsSpec(t *testing.T) {
+	spec := writeInput(t, t.TempDir(), "spec.csv",
+		"timestamp, deviceId, eventCode, payload, received",
+		"2016-03-01T20:00:00Z, 0000000001, 50, , ",
+		"2016-03-01 20:00:05 +0000 UTC, 00000000000000A2, `G`VOD Category, 0A0b, 2016-03-01T20:00:09",
+		"1980-01-06T00:00:00Z, 0000000003, 4B, 0A, ")
+	var out strings.Builder
+	encoded, err := encodeEvents(testConfig(), spec, &out)
+	if err != nil || encoded != 3 {
+		t.Fatalf("encodeEvents = %d, %v", encoded, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	wantLines := []string{
+		"0000000001 50" + fmt.Sprintf("%08X", time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC).Unix()-UTC_GPS_Diff),
+		"2016-03-01T20:00:09 00000000000000A2 47" + fmt.Sprintf("%08X", time.Date(2016, 3, 1, 20, 0, 5, 0, time.UTC).Unix()-UTC_GPS_Diff) + "0A0B",
+		// The GPS epoch is zero seconds
+		"0000000003 4B000000000A",
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Fatalf("encoded:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(wantLines, "\n"))
+	}
+
+	cfg := testConfig()
+	cfg.minDate = time.Time{}
+	wantTimes := []time.Time{
+		time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC),
+		time.Date(2016, 3, 1, 20, 0, 5, 0, time.UTC),
+		time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC),
+	}
+	for i, line := range lines {
+		timestamp, _, _, _, _, err := parseEvent(cfg, line, nil, LineSource{"MSO1", "spec_MSO1.raw", i + 1}, time.Now())
+		if err != nil || !timestamp.Equal(wantTimes[i]) {
+			t.Errorf("parseEvent(%s) = %v, %v, want %v", line, timestamp, err, wantTimes[i])
+		}
+	}
+}
+
+func TestEncodeEventsSpecErrors(t *testing.T) {
+	for _, lines := range [][]string{
+		{"timestamp, eventCode", "2016-03-01T20:00:00Z, 50"},
+		{"timestamp, deviceId, eventCode", "2016-03-01T20:00:00Z, dev1, ZZ"},
+		{"timestamp, deviceId, eventCode", "yesterday, dev1, 50"},
+		{"timestamp, deviceId, eventCode, payload", "2016-03-01T20:00:00Z, dev1, 50, XYZ"},
+		{"timestamp, deviceId, eventCode", "2016-03-01T20:00:00Z, dev1"},
+		{"timestamp, deviceId, eventCode, received", "2016-03-01T20:00:00Z, dev1, 50, 2016-03-01 20:00:09"},
+	} {
+		spec := writeInput(t, t.TempDir(), "spec.csv", lines...)
+		if _, err := encodeEvents(testConfig(), spec, io.Discard); err == nil {
+			t.Errorf("encodeEvents of %q, want an error", lines)
+		}
+	}
+}
+
+// The payload after a wider timestamp: the Info Screen type V read past the 6 bytes
+func TestParseEventWideTimestampPayload(t *testing.T) {
+	timestamp := time.Date(2024, 3, 5, 20, 15, 0, 0, time.UTC)
+	source := LineSource{"MSO1", "wide.raw", 1}
+	defer delete(timestampWidths, "49")
+	for _, width := range []int{4, 5, 6} {
+		timestampWidths["49"] = width
+		cfg := testConfig()
+		cfg.vodLog = true
+		eventLogChan := make(chan EventLogEntry, 1)
+		// 'V' in the first payload byte
+		clickString, err := encodeEvent(cfg, "49", timestamp, "56")
+		if err != nil {
+			t.Fatalf("%d bytes: %v", width, err)
+		}
+		parsed, _, _, _, _, err := parseEvent(cfg, "0000000001 "+clickString, eventLogChan, source, time.Now())
+		if err != nil || !parsed.Equal(timestamp) {
+			t.Errorf("%d bytes: %v %v, want %v", width, parsed, err, timestamp)
+			continue
+		}
+		select {
+		case entry := <-eventLogChan:
+			if !strings.HasSuffix(entry.eventcode, " / Type V") {
+				t.Errorf("%d bytes: VOD entry %q, want the type V", width, entry.eventcode)
+			}
+		default:
+			t.Errorf("%d bytes: no VOD entry for %s", width, clickString)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix of the environment variables standing in for the flags
+const envPrefix = "CSBA_"
+
+// Environment names of the one and two letter flags, S and s would collide otherwise
+var envNames = map[string]string{
+	"f":   "FILE",
+	"d":   "DIR",
+	"x":   "EXTENSION",
+	"t":   "DEBUG",
+	"s":   "FORMAT",
+	"o":   "OUTPUT",
+	"c":   "CONCURRENCY",
+	"v":   "VERBOSE",
+	"S":   "SUPRESS",
+	"P":   "PRIMETIME",
+	"PC":  "CUMULATIVE_PRIMETIME",
+	"L":   "EVENTS_LOG",
+	"M":   "MAX_EVENTS_PER_FILE",
+	"VOD": "VOD",
+}
+
+// Environment variable of the flag, e.g. CSBA_CONCURRENCY for -c and CSBA_OUTDIR for -outdir
+func envName(flagName string) string {
+	if name, ok := envNames[flagName]; ok {
+		return envPrefix + name
+	}
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Sets the flags left out of the command line from their CSBA_* environment variables,
+// the precedence is: the flag, then the environment, then the default.
+// Returns the names of the flags set, to be logged once the log level is known.
+func applyEnvironment() ([]string, error) {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var err error
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := flag.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("Wrong %s=%s: %v", envName(f.Name), value, setErr)
+			return
+		}
+		names = append(names, f.Name)
+	})
+	return names, err
+}
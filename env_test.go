@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		flagName string
+		want     string
+	}{
+		{"c", "CSBA_CONCURRENCY"},
+		{"S", "CSBA_SUPRESS"},
+		{"s", "CSBA_FORMAT"},
+		{"outdir", "CSBA_OUTDIR"},
+		{"max-gap", "CSBA_MAX_GAP"},
+	}
+	for _, test := range tests {
+		if got := envName(test.flagName); got != test.want {
+			t.Errorf("envName(%q) = %q, want %q", test.flagName, got, test.want)
+		}
+	}
+}
+
+func TestApplyEnvironmentReturnsSetFlags(t *testing.T) {
+	previous := flag.Lookup("order").Value.String()
+	defer flag.Set("order", previous)
+
+	t.Setenv(envName("order"), orderSize)
+	names, err := applyEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == "order"
+	}
+	if !found {
+		t.Errorf("applyEnvironment() = %v, want order in it", names)
+	}
+	if got := flag.Lookup("order").Value.String(); got != orderSize {
+		t.Errorf("-order = %q, want %q", got, orderSize)
+	}
+}
+
+func TestApplyEnvironmentWrongValue(t *testing.T) {
+	t.Setenv(envName("max-files"), "many")
+	if _, err := applyEnvironment(); err == nil {
+		t.Error("applyEnvironment() with CSBA_MAX_FILES=many, want an error")
+	}
+}
+
+// The flag given on the command line wins over its environment variable
+func TestApplyEnvironmentPrecedence(t *testing.T) {
+	previousTop, previousSmooth := flag.Lookup("top").Value.String(), flag.Lookup("smooth").Value.String()
+	defer func() {
+		flag.Set("top", previousTop)
+		flag.Set("smooth", previousSmooth)
+	}()
+
+	if err := flag.Set("top", "3"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envName("top"), "7")
+	t.Setenv(envName("smooth"), "5")
+	names, err := applyEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if name == "top" {
+			t.Errorf("applyEnvironment() = %v, -top was given", names)
+		}
+	}
+	if got := flag.Lookup("top").Value.String(); got != "3" {
+		t.Errorf("-top = %s, want the given 3", got)
+	}
+	if got := flag.Lookup("smooth").Value.String(); got != "5" {
+		t.Errorf("-smooth = %s, want 5 from %s", got, envName("smooth"))
+	}
+}
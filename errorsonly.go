@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const errorLinesFileName = "errorLines.txt"
+
+// Failing lines of the files for the -errors-only triage, parsed without the simulation
+func collectErrorLines(cfg *Config, files []string, now time.Time) []ErrorLogEntry {
+	var errors []ErrorLogEntry
+	for _, fileName := range files {
+		logDebug("Collecting errors: %s", fileName)
+		file, err := openInput(fileName)
+		if err != nil {
+			logWarn("Error opening file: %v", err)
+			errors = append(errors, newErrorLogEntry(fileName, 0, "", err))
+			continue
+		}
+
+		mso := msoName(fileName)
+		scanner := newLineScanner(file)
+		lineNo := 0
+		var columns *CsvColumns
+		if cfg.inputFormat == csvInput && scanner.Scan() {
+			lineNo++
+			if columns, err = parseCsvHeader(scanner.Text()); err != nil {
+				errors = append(errors, newErrorLogEntry(fileName, lineNo, scanner.Text(), err))
+				file.Close()
+				continue
+			}
+		}
+		for scanner.Scan() {
+			lineNo++
+			line := scanner.Text()
+			if isBlankLine(line) {
+				continue
+			}
+			if _, _, _, _, _, err := parseInputEvent(cfg, line, columns, nil, LineSource{mso, fileName, lineNo}, now); err != nil {
+				errors = append(errors, newErrorLogEntry(fileName, lineNo, cfg.anonymizeLine(line, columns), err))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			errors = append(errors, newErrorLogEntry(fileName, lineNo+1, "", err))
+		}
+		file.Close()
+	}
+	return errors
+}
+
+// The failing lines verbatim after the file:lineNo and the error category, tab separated
+func printErrorLines(errors []ErrorLogEntry) error {
+	w, err := createOutputFile(errorLinesFileName)
+	if err != nil {
+		return err
+	}
+	for _, logEntry := range errors {
+		fmt.Fprintf(w, "%s:%d\t%s\t%s\n", logEntry.fileName, logEntry.lineNo, logEntry.category, logEntry.line)
+	}
+	return w.Close()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Only the failing lines of the valid and invalid mix, verbatim with their file and line
+func TestErrorLines(t *testing.T) {
+	dir := withOutputDir(t)
+	input := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	old := rawLine(t, "dev2", "50", time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), "")
+	mixed := writeInput(t, input, "mixed_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		"garbage",
+		"",
+		rawLine(t, "dev1", "4D", start.Add(time.Second), "AB"),
+		"a b c d",
+		old)
+	valid := writeInput(t, input, "valid_MSO1.raw", rawLine(t, "dev3", "50", start, ""))
+	missing := filepath.Join(input, "missing_MSO1.raw")
+
+	cfg := testConfig()
+	errors := collectErrorLines(cfg, []string{mixed, valid, missing}, time.Now())
+	if err := printErrorLines(errors); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%[1]s:2\tformat\tgarbage\n%[1]s:5\tformat\ta b c d\n%[1]s:6\tdate\t%[2]s\n%[3]s:0\tread\t\n",
+		mixed, old, missing)
+	if got := readOutput(t, dir, errorLinesFileName); got != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", errorLinesFileName, got, want)
+	}
+}
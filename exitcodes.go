@@ -0,0 +1,35 @@
+package main
+
+import "os"
+
+// Exit codes of a completed run, for cron and CI to tell a run without useful results:
+//
+//	0   clean run
+//	2   parse errors above -exit-errors
+//	3   fewer valid events than -exit-min-events, e.g. nothing in the directory parsed
+//	130 interrupted by a signal, partial results
+//	-1  (255) usage, aborted run, too many parse errors with -max-errors or strict mode failures
+//
+// No valid events wins over the parse errors, it is the worse of the two.
+const (
+	exitClean       = 0
+	exitParseErrors = 2
+	exitNoEvents    = 3
+)
+
+// Exit code by the data health of the run
+func dataHealthExitCode(parseErrors, validEvents int) int {
+	if validEvents < exitMinEvents {
+		return exitNoEvents
+	}
+	if exitMaxErrors >= 0 && parseErrors > exitMaxErrors {
+		return exitParseErrors
+	}
+	return exitClean
+}
+
+// Every exit of the run goes through here, the profiles of a profiled run are written first
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"sort"
+)
+
+// -order of the files found in the directory or by the glob
+const (
+	orderName  = "name"
+	orderMtime = "mtime"
+	// Newest first, -max-files keeps the latest files
+	orderMtimeDesc = "mtime-desc"
+	orderSize      = "size"
+)
+
+// Sorts the files by the -order, with the infos captured while listing them.
+// The name breaks the ties, files with no info go first.
+func sortFiles(fileList []string, infos map[string]os.FileInfo) {
+	sort.Slice(fileList, func(i, j int) bool {
+		a, b := infos[fileList[i]], infos[fileList[j]]
+		if fileOrder != orderName && (a == nil) != (b == nil) {
+			return a == nil
+		}
+		if a != nil && b != nil {
+			switch fileOrder {
+			case orderMtime:
+				if !a.ModTime().Equal(b.ModTime()) {
+					return a.ModTime().Before(b.ModTime())
+				}
+			case orderMtimeDesc:
+				if !a.ModTime().Equal(b.ModTime()) {
+					return a.ModTime().After(b.ModTime())
+				}
+			case orderSize:
+				if a.Size() != b.Size() {
+					return a.Size() < b.Size()
+				}
+			}
+		}
+		return fileList[i] < fileList[j]
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The mtime, newest first and size orders differ from the name order
+func TestWalkDirOrder(t *testing.T) {
+	saved := fileOrder
+	defer func() { fileOrder = saved }()
+
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	files := []struct {
+		name    string
+		size    int
+		modTime time.Time
+	}{
+		{"a_MSO1.raw", 30, start.Add(2 * time.Hour)},
+		{"b_MSO1.raw", 10, start},
+		{"c_MSO1.raw", 20, start.Add(time.Hour)},
+		// Same size as c, the name breaks the tie
+		{"d_MSO1.raw", 20, start.Add(3 * time.Hour)},
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", file.size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, file.modTime, file.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{orderName, []string{"a", "b", "c", "d"}},
+		{orderMtime, []string{"b", "c", "a", "d"}},
+		{orderMtimeDesc, []string{"d", "a", "c", "b"}},
+		{orderSize, []string{"b", "c", "d", "a"}},
+	}
+	for _, test := range tests {
+		fileOrder = test.order
+		var got []string
+		for _, path := range walkDir(dir) {
+			got = append(got, strings.TrimSuffix(filepath.Base(path), "_MSO1.raw"))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("-order %s: %v, want %v", test.order, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Any number of directories in a -glob pattern, e.g. data/**/clickstream_*.raw
+const globStar = "**"
+
+// Files matching the -glob pattern, in the -order. Directories are left out,
+// the zip and tar archives are taken as their entries.
+// Without ** this is filepath.Glob, with it the tree under the leading literal directories is walked.
+func globFiles(pattern string) ([]string, error) {
+	var matches []string
+	if !strings.Contains(pattern, globStar) {
+		var err error
+		if matches, err = filepath.Glob(pattern); err != nil {
+			return nil, err
+		}
+	} else {
+		// Pattern is validated once up front, the walk ignores the match errors.
+		// By the element, a literal prefix would end the match before the error.
+		for _, part := range splitPath(pattern) {
+			if _, err := filepath.Match(part, ""); err != nil {
+				return nil, err
+			}
+		}
+		root := globRoot(pattern)
+		err := filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				logWarn("Error reading %s: %v", path, err)
+				return nil
+			}
+			if matchGlob(splitPath(pattern), splitPath(path)) {
+				matches = append(matches, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fileList := []string{}
+	infos := make(map[string]os.FileInfo)
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		inputs := []string{path}
+		infos[path] = info
+		if isArchive(path) {
+			if inputs, err = archiveEntries(path, infos); err != nil {
+				logWarn("Error reading archive %s: %v", path, err)
+				continue
+			}
+		}
+		for _, input := range inputs {
+			if !isMsoSelected(input) {
+				msoSkippedFiles++
+				logDebug("Skipped by MSO filter: %s", input)
+				continue
+			}
+			fileList = append(fileList, input)
+			logDebug("Added: %s", input)
+		}
+	}
+	sortFiles(fileList, infos)
+	return fileList, nil
+}
+
+// Leading directories of the pattern without wildcards, where the walk starts
+func globRoot(pattern string) string {
+	parts := splitPath(pattern)
+	var literal []string
+	for _, part := range parts[:len(parts)-1] {
+		if strings.ContainsAny(part, "*?[\\") {
+			break
+		}
+		literal = append(literal, part)
+	}
+	if len(literal) == 0 {
+		if filepath.IsAbs(pattern) {
+			return string(filepath.Separator)
+		}
+		return "."
+	}
+	root := filepath.Join(literal...)
+	if filepath.IsAbs(pattern) {
+		root = string(filepath.Separator) + root
+	}
+	return root
+}
+
+func splitPath(path string) []string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// Matches the path elements to the pattern ones, ** matches zero or more elements
+func matchGlob(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == globStar {
+			for i := 0; i <= len(path); i++ {
+				if matchGlob(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"2024-02/clickstream_b_MSO1.raw",
+		"2024-01/clickstream_a_MSO1.raw",
+		"2024-01/other_MSO1.raw",
+		"2024-01/deep/x/clickstream_d_MSO1.raw",
+		"2023-12/clickstream_c_MSO1.raw",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("dev1 5000000000\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := func(names ...string) []string {
+		for i := range names {
+			names[i] = filepath.Join(root, names[i])
+		}
+		return names
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"2024-*/clickstream_*.raw", paths("2024-01/clickstream_a_MSO1.raw", "2024-02/clickstream_b_MSO1.raw")},
+		{"**/clickstream_*.raw", paths("2023-12/clickstream_c_MSO1.raw", "2024-01/clickstream_a_MSO1.raw",
+			"2024-01/deep/x/clickstream_d_MSO1.raw", "2024-02/clickstream_b_MSO1.raw")},
+		{"2024-01/**/*.raw", paths("2024-01/clickstream_a_MSO1.raw", "2024-01/deep/x/clickstream_d_MSO1.raw", "2024-01/other_MSO1.raw")},
+		{"2025-*/*.raw", []string{}},
+	}
+	for _, test := range tests {
+		got, err := globFiles(filepath.Join(root, test.pattern))
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("globFiles(%s) = %v, %v, want %v", test.pattern, got, err, test.want)
+		}
+	}
+	if _, err := globFiles(filepath.Join(root, "**", "[")); err == nil {
+		t.Error("globFiles of a wrong pattern, want an error")
+	}
+}
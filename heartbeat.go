@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Interim totals of a Process run, updated by the file workers as they go
+type Progress struct {
+	files uint64
+	lines uint64
+	// Valid events, the lines parsed and not left out by -min-size
+	events   uint64
+	packages uint64
+	// Parse errors, -max-errors counts them
+	errors uint64
+}
+
+// Prints the interim totals to stderr every interval until stopped
+func startHeartbeat(progress *Progress, interval time.Duration, totalFiles int, startTime time.Time) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintln(os.Stderr, progress.line(time.Now(), totalFiles, startTime))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+func (progress *Progress) line(now time.Time, totalFiles int, startTime time.Time) string {
+	return fmt.Sprintf("%s files: %d/%d\t lines: %d\t events: %d\t packages: %d\t errors: %d\t elapsed: %v",
+		now.Format("15:04:05"),
+		atomic.LoadUint64(&progress.files), totalFiles,
+		atomic.LoadUint64(&progress.lines),
+		atomic.LoadUint64(&progress.events),
+		atomic.LoadUint64(&progress.packages),
+		atomic.LoadUint64(&progress.errors),
+		now.Sub(startTime).Truncate(time.Second))
+}
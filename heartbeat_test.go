@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The events of the heartbeat are the valid ones, the lines are all of them
+func TestProgressLine(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	fileName := writeInput(t, t.TempDir(), "a_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		"dev2 not a clickstring",
+		rawLine(t, "dev2", "43", start.Add(time.Second), "0A0B"))
+	run := &processRun{}
+	for range processFiles(context.Background(), testConfig(), run, []string{fileName}, nil, newBufferState(), time.Now(), nil) {
+	}
+
+	startTime := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	line := run.progress.line(startTime.Add(90*time.Second), 1, startTime)
+	want := "10:01:30 files: 1/1\t lines: 3\t events: 2\t"
+	if !strings.HasPrefix(line, want) || !strings.Contains(line, "errors: 1\t elapsed: 1m30s") {
+		t.Errorf("progress line = %q, want %q... errors: 1 elapsed: 1m30s", line, want)
+	}
+}
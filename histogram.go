@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const eventHistogramFileName = "eventHistogram.csv"
+
+// Event name counted under another one in the histogram, from -merge-codes
+var mergedEvents = make(map[string]string)
+
+// Parses the -merge-codes list of from=into pairs, e.g. 43=63 counts the verbose
+// channel changes as the brief ones. Codes or built-in event names are accepted.
+// Must run after initEventNames, the pairs are kept by the reported event names.
+func parseMergeCodes(list string) error {
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, "=")
+		if len(parts) != 2 {
+			return fmt.Errorf("Wrong merge codes pair: [%s], expected from=into", pair)
+		}
+		from, ok := findCode(strings.TrimSpace(parts[0]))
+		if !ok {
+			return fmt.Errorf("Unknown code in merge codes pair: [%s]", pair)
+		}
+		into, ok := findCode(strings.TrimSpace(parts[1]))
+		if !ok {
+			return fmt.Errorf("Unknown code in merge codes pair: [%s]", pair)
+		}
+		if from == into {
+			continue
+		}
+		mergedEvents[eventNames[from]] = eventNames[into]
+	}
+
+	// No chains, a code merged into a merged one ends in the final target
+	for from := range mergedEvents {
+		into, seen := mergedEvents[from], map[string]bool{from: true}
+		for next, ok := mergedEvents[into]; ok; next, ok = mergedEvents[into] {
+			if seen[into] {
+				return fmt.Errorf("Circular merge codes for [%s]", from)
+			}
+			seen[into] = true
+			into = next
+		}
+		mergedEvents[from] = into
+	}
+	return nil
+}
+
+// Event name the histogram counts the event under
+func histogramEvent(eventCode string) string {
+	if into, ok := mergedEvents[eventCode]; ok {
+		return into
+	}
+	return eventCode
+}
+
+// Valid events by MSO and event name, the most frequent first
+func printEventHistogram() {
+	if len(msoStats) == 0 {
+		return
+	}
+
+	w, err := createOutputFile(eventHistogramFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "mso, eventCode, events")
+	for _, mso := range sortedMsoNames() {
+		codes := msoStats[mso].codes
+		for _, name := range sortedByCount(codes) {
+			fmt.Fprintf(w, "%s, %s, %d\n", mso, name, codes[name])
+		}
+	}
+	w.Close()
+}
+
+// Histogram keys, the most frequent first, then by name
+func sortedByCount(counts map[string]int) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	httpSinkRetries = 3
+	httpSinkBackoff = 500 * time.Millisecond
+)
+
+// POSTs the packages as json arrays of batchSize packages to an HTTP endpoint
+type HttpSink struct {
+	url       string
+	batchSize int
+	client    *http.Client
+	// Stop on the first batch that fails after the retries, instead of going on
+	abortOnError bool
+}
+
+func newHttpSink(url string, batchSize int, timeout time.Duration, abortOnError bool) *HttpSink {
+	return &HttpSink{
+		url:          url,
+		batchSize:    batchSize,
+		client:       &http.Client{Timeout: timeout},
+		abortOnError: abortOnError,
+	}
+}
+
+// Sends all the packages, returns the number of packages accepted by the endpoint
+func (sink *HttpSink) send(packages PackageList) (int, error) {
+	accepted := 0
+	var lastErr error
+	for start := 0; start < len(packages); start += sink.batchSize {
+		end := start + sink.batchSize
+		if end > len(packages) {
+			end = len(packages)
+		}
+		if err := sink.postBatch(packages[start:end]); err != nil {
+			logError("HTTP sink batch %d-%d failed: %v", start, end, err)
+			lastErr = err
+			if sink.abortOnError {
+				return accepted, err
+			}
+			continue
+		}
+		accepted += end - start
+	}
+	return accepted, lastErr
+}
+
+func (sink *HttpSink) postBatch(batch PackageList) error {
+	records := make([]packageRecord, 0, len(batch))
+	for _, pkg := range batch {
+		records = append(records, newPackageRecord(pkg))
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	backoff := httpSinkBackoff
+	for attempt := 0; ; attempt++ {
+		retry := false
+		err = sink.post(body)
+		if err != nil {
+			if statusErr, ok := err.(httpStatusError); !ok || statusErr.retryable() {
+				retry = true
+			}
+		}
+		if err == nil || !retry || attempt == httpSinkRetries {
+			return err
+		}
+		logWarn("HTTP sink: %v, retrying in %v", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Non 2xx response, only the server side errors are worth a retry
+type httpStatusError struct {
+	status string
+	code   int
+}
+
+func (err httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected response: %s", err.status)
+}
+
+func (err httpStatusError) retryable() bool {
+	return err.code >= 500 || err.code == http.StatusTooManyRequests
+}
+
+func (sink *HttpSink) post(body []byte) error {
+	response, err := sink.client.Post(sink.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// Drained for the connection reuse
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return httpStatusError{response.Status, response.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sinkPackages(n int) PackageList {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	packages := make(PackageList, 0, n)
+	for i := 0; i < n; i++ {
+		packages = append(packages, Pack(start.Add(time.Duration(i)*time.Second), "dev1", "Pulse", "MSO1"))
+	}
+	return packages
+}
+
+// Batches of -batch-size packages, a server error retried
+func TestHttpSinkBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches []int
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var records []packageRecord
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&records) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, len(records))
+	}))
+	defer server.Close()
+
+	accepted, err := newHttpSink(server.URL, 3, time.Second, false).send(sinkPackages(7))
+	if err != nil || accepted != 7 {
+		t.Fatalf("send = %d, %v, want 7 accepted", accepted, err)
+	}
+	if len(batches) != 3 || batches[0] != 3 || batches[1] != 3 || batches[2] != 1 {
+		t.Errorf("batches %v, want [3 3 1]", batches)
+	}
+}
+
+// A client error is not retried, the batch is lost or the send stops with the abort
+func TestHttpSinkRejected(t *testing.T) {
+	var mu sync.Mutex
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts++
+		if posts == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	accepted, err := newHttpSink(server.URL, 2, time.Second, false).send(sinkPackages(4))
+	if err == nil || accepted != 2 || posts != 2 {
+		t.Errorf("send = %d, %v after %d posts, want 2 accepted, the error and 2 posts", accepted, err, posts)
+	}
+
+	posts = 0
+	accepted, err = newHttpSink(server.URL, 2, time.Second, true).send(sinkPackages(4))
+	if err == nil || accepted != 0 || posts != 1 {
+		t.Errorf("send with the abort = %d, %v after %d posts, want 0 accepted, the error and 1 post", accepted, err, posts)
+	}
+}
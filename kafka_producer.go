@@ -0,0 +1,37 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaWriter struct {
+	writer *kafka.Writer
+}
+
+func init() {
+	newKafkaProducer = func(broker, topic string) (KafkaProducer, error) {
+		return &kafkaWriter{&kafka.Writer{
+			Addr:     kafka.TCP(broker),
+			Topic:    topic,
+			Balancer: &kafka.Hash{},
+			// The sink batches, every publish is written at once
+			BatchSize: kafkaBatchSize,
+		}}, nil
+	}
+}
+
+func (producer *kafkaWriter) publish(messages []KafkaMessage) error {
+	batch := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		batch[i] = kafka.Message{Key: message.Key, Value: message.Value}
+	}
+	return producer.writer.WriteMessages(context.Background(), batch...)
+}
+
+func (producer *kafkaWriter) Close() error {
+	return producer.writer.Close()
+}
@@ -0,0 +1,27 @@
+//go:build kafka
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// Against a real broker, e.g. CSBA_TEST_KAFKA=localhost:9092 go test -tags kafka -run Kafka
+func TestKafkaProducerIntegration(t *testing.T) {
+	broker := os.Getenv("CSBA_TEST_KAFKA")
+	if broker == "" {
+		t.Skip("CSBA_TEST_KAFKA is not set")
+	}
+	sink, err := openKafkaSink(broker, "csbufferanalizer-test", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	sink.addEvent(start, "", "dev1", "Pulse", "MSO1", 5)
+	sink.addPackages(PackageList{Pack(start, "dev1", "Pulse", "MSO1")})
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const kafkaBatchSize = 1000
+
+// Message for the Kafka topic, keyed by the device Id so a device stays in one partition
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// Publishes the message batches, the implementation is in kafka_producer.go
+type KafkaProducer interface {
+	publish(messages []KafkaMessage) error
+	Close() error
+}
+
+// Set by kafka_producer.go, build with -tags kafka
+var newKafkaProducer func(broker, topic string) (KafkaProducer, error)
+
+// Publishes the parsed events and the packages as json messages, in batches of
+// kafkaBatchSize and at least every flush interval.
+// Safe for use by the concurrent file workers.
+type KafkaSink struct {
+	sync.Mutex
+	producer KafkaProducer
+	pending  []KafkaMessage
+	err      error
+	stop     chan bool
+	done     sync.WaitGroup
+}
+
+var kafkaSink *KafkaSink
+
+// Kafka message of a parsed event
+type kafkaEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Received  string    `json:"received"`
+	DeviceId  string    `json:"deviceId"`
+	EventCode string    `json:"eventCode"`
+	Mso       string    `json:"mso"`
+	EventSize int       `json:"eventSize"`
+}
+
+// Kafka message of a package
+type kafkaPackage struct {
+	Type string `json:"type"`
+	packageRecord
+}
+
+func openKafkaSink(broker, topic string, flushInterval time.Duration) (*KafkaSink, error) {
+	if newKafkaProducer == nil {
+		return nil, errors.New("Kafka producer is not available, rebuild with -tags kafka")
+	}
+	if topic == "" {
+		return nil, errors.New("Kafka topic is not set, use -topic")
+	}
+	producer, err := newKafkaProducer(broker, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &KafkaSink{producer: producer, stop: make(chan bool)}
+	sink.done.Add(1)
+	go func() {
+		defer sink.done.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sink.Lock()
+				sink.flush()
+				sink.Unlock()
+			case <-sink.stop:
+				return
+			}
+		}
+	}()
+	return sink, nil
+}
+
+// The first error stops the sink, the rest of the run is not affected
+func (sink *KafkaSink) add(deviceId string, value interface{}) {
+	if sink.err != nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		sink.fail(err)
+		return
+	}
+	sink.pending = append(sink.pending, KafkaMessage{[]byte(deviceId), data})
+	if len(sink.pending) >= kafkaBatchSize {
+		sink.flush()
+	}
+}
+
+func (sink *KafkaSink) flush() {
+	if sink.err != nil || len(sink.pending) == 0 {
+		return
+	}
+	if err := sink.producer.publish(sink.pending); err != nil {
+		sink.fail(err)
+	}
+	sink.pending = nil
+}
+
+func (sink *KafkaSink) fail(err error) {
+	logError("Kafka sink error: %v", err)
+	sink.err = err
+	sink.pending = nil
+}
+
+func (sink *KafkaSink) addEvent(timestamp time.Time, received, deviceId, eventCode, mso string, eventSize int) {
+	sink.Lock()
+	defer sink.Unlock()
+	sink.add(deviceId, kafkaEvent{"event", timestamp, received, deviceId, eventCode, mso, eventSize})
+}
+
+func (sink *KafkaSink) addPackages(packages PackageList) {
+	sink.Lock()
+	defer sink.Unlock()
+	for _, pkg := range packages {
+		sink.add(pkg.deviceId, kafkaPackage{"package", newPackageRecord(pkg)})
+	}
+}
+
+func (sink *KafkaSink) Close() error {
+	close(sink.stop)
+	sink.done.Wait()
+
+	sink.Lock()
+	defer sink.Unlock()
+	sink.flush()
+	err := sink.err
+	if closeErr := sink.producer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Producer of the messages published, in memory
+type fakeKafkaProducer struct {
+	sync.Mutex
+	batches [][]KafkaMessage
+	err     error
+	closed  bool
+}
+
+func (producer *fakeKafkaProducer) publish(messages []KafkaMessage) error {
+	producer.Lock()
+	defer producer.Unlock()
+	producer.batches = append(producer.batches, messages)
+	return producer.err
+}
+
+func (producer *fakeKafkaProducer) Close() error {
+	producer.closed = true
+	return nil
+}
+
+func withFakeKafkaProducer(t *testing.T, producer *fakeKafkaProducer) {
+	saved := newKafkaProducer
+	newKafkaProducer = func(broker, topic string) (KafkaProducer, error) { return producer, nil }
+	t.Cleanup(func() { newKafkaProducer = saved })
+}
+
+func TestKafkaSink(t *testing.T) {
+	producer := &fakeKafkaProducer{}
+	withFakeKafkaProducer(t, producer)
+	sink, err := openKafkaSink("localhost:9092", "clicks", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	sink.addEvent(start, "", "dev1", "Pulse", "MSO1", 5)
+	for i := 0; i < kafkaBatchSize; i++ {
+		sink.addPackages(PackageList{Pack(start, "dev2", "Pulse", "MSO1")})
+	}
+	if len(producer.batches) != 1 || len(producer.batches[0]) != kafkaBatchSize {
+		t.Fatalf("%d batches before the close, want 1 full batch", len(producer.batches))
+	}
+	if err = sink.Close(); err != nil || !producer.closed {
+		t.Fatalf("Close = %v, closed %v", err, producer.closed)
+	}
+	if len(producer.batches) != 2 || len(producer.batches[1]) != 1 {
+		t.Fatalf("%d batches after the close, want the rest flushed", len(producer.batches))
+	}
+
+	first := producer.batches[0][0]
+	var event kafkaEvent
+	if err = json.Unmarshal(first.Value, &event); err != nil {
+		t.Fatal(err)
+	}
+	if string(first.Key) != "dev1" || event.Type != "event" || event.EventSize != 5 || !event.Timestamp.Equal(start) {
+		t.Errorf("first message %s: %s", first.Key, first.Value)
+	}
+	if key := string(producer.batches[1][0].Key); key != "dev2" {
+		t.Errorf("package key %s, want dev2", key)
+	}
+}
+
+// Published every flush interval, the first error stops the sink
+func TestKafkaSinkFlushInterval(t *testing.T) {
+	producer := &fakeKafkaProducer{err: errors.New("broker down")}
+	withFakeKafkaProducer(t, producer)
+	sink, err := openKafkaSink("localhost:9092", "clicks", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.addEvent(time.Now(), "", "dev1", "Pulse", "MSO1", 5)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		producer.Lock()
+		published := len(producer.batches)
+		producer.Unlock()
+		if published > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	sink.addEvent(time.Now(), "", "dev1", "Pulse", "MSO1", 5)
+	if err = sink.Close(); err == nil || len(producer.batches) != 1 {
+		t.Errorf("Close = %v after %d batches, want the broker error after 1", err, len(producer.batches))
+	}
+}
+
+func TestOpenKafkaSinkErrors(t *testing.T) {
+	saved := newKafkaProducer
+	defer func() { newKafkaProducer = saved }()
+	newKafkaProducer = nil
+	if _, err := openKafkaSink("localhost:9092", "clicks", time.Second); err == nil {
+		t.Error("openKafkaSink without the producer, want an error")
+	}
+	withFakeKafkaProducer(t, &fakeKafkaProducer{})
+	if _, err := openKafkaSink("localhost:9092", "", time.Second); err == nil {
+		t.Error("openKafkaSink without -topic, want an error")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	keyPressCode              = "4B"
+	keyPressHistogramFileName = "keypressHistogram.csv"
+	// The keycode follows the code byte and the 4 timestamp bytes
+	keyCodeOffset = 5
+)
+
+var (
+	// Remote key names by the hex keycode, from the -keys file
+	keyNames map[string]string
+	// Keycode length in bytes, the same for all the keys in the file
+	keyCodeLength int
+)
+
+// Reads a JSON object mapping the hex keycodes to the key names, e.g. {"0A": "Guide", "0B": "Info"}.
+// All keycodes are 1 or 2 bytes, the same length for the whole file.
+func loadKeyNames(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var keys map[string]string
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("Wrong keys file %s: %v", fileName, err)
+	}
+
+	keyNames = make(map[string]string, len(keys))
+	for code, name := range keys {
+		code = strings.ToUpper(code)
+		if !isHex(code) || (len(code) != 2 && len(code) != 4) {
+			return fmt.Errorf("Wrong keycode in %s: [%s], expected 1 or 2 hex bytes", fileName, code)
+		}
+		if keyCodeLength == 0 {
+			keyCodeLength = len(code) / 2
+		} else if keyCodeLength != len(code)/2 {
+			return fmt.Errorf("Keycodes of different length in %s: [%s]", fileName, code)
+		}
+		keyNames[code] = name
+	}
+	if keyCodeLength == 0 {
+		keyCodeLength = 1
+	}
+	return nil
+}
+
+func isHex(str string) bool {
+	for _, c := range str {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Key name of a Key Press clickstring, the raw hex keycode for the keys not in the file.
+// shift is the timestamp bytes over the 4 of keyCodeOffset.
+func decodeKeyPress(clickString string, shift int) (string, bool) {
+	start := (keyCodeOffset + shift) * 2
+	end := start + keyCodeLength*2
+	if len(clickString) < end {
+		return "", false
+	}
+	code := strings.ToUpper(clickString[start:end])
+	if name, ok := keyNames[code]; ok {
+		return name, true
+	}
+	return "0x" + code, true
+}
+
+// Clickstring is the last token of the line, from the event code past the codeOffset framing bytes
+func lineClickString(line string, codeOffset int) string {
+	clickString := line[strings.LastIndex(line, " ")+1:]
+	if len(clickString) < codeOffset*2 {
+		return ""
+	}
+	return clickString[codeOffset*2:]
+}
+
+// Key presses by MSO and key, the most frequent first
+func printKeyPressHistogram() {
+	if len(msoStats) == 0 {
+		return
+	}
+
+	w, err := createOutputFile(keyPressHistogramFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "mso, key, presses")
+	for _, mso := range sortedMsoNames() {
+		keys := msoStats[mso].keys
+		for _, key := range sortedByCount(keys) {
+			fmt.Fprintf(w, "%s, %s, %d\n", mso, key, keys[key])
+		}
+	}
+	w.Close()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadTestKeyNames(t *testing.T, content string) error {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyNames, keyCodeLength = nil, 0
+	t.Cleanup(func() { keyNames, keyCodeLength = nil, 0 })
+	return loadKeyNames(fileName)
+}
+
+func TestDecodeKeyPress(t *testing.T) {
+	if err := loadTestKeyNames(t, `{"0A": "Guide", "0b": "Info", "20": "Ok"}`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clickString string
+		shift       int
+		want        string
+		ok          bool
+	}{
+		{"4B5A0B1C2D0A", 0, "Guide", true},
+		{"4B5A0B1C2D0b01", 0, "Info", true},
+		{"4B5A0B1C2D20", 0, "Ok", true},
+		{"4B5A0B1C2D7F", 0, "0x7F", true},
+		{"4B005A0B1C2D0A", 1, "Guide", true},
+		{"4B5A0B1C2D", 0, "", false},
+	}
+	for _, test := range tests {
+		got, ok := decodeKeyPress(test.clickString, test.shift)
+		if got != test.want || ok != test.ok {
+			t.Errorf("decodeKeyPress(%s, %d) = %q, %v, want %q, %v", test.clickString, test.shift, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestLoadKeyNamesErrors(t *testing.T) {
+	for _, content := range []string{`{"0G": "Bad"}`, `{"0A0": "Odd"}`, `{"0A": "One", "0B0C": "Two"}`, `[]`} {
+		if err := loadTestKeyNames(t, content); err == nil {
+			t.Errorf("loadKeyNames(%s), want an error", content)
+		}
+	}
+}
+
+// The histogram by MSO, the most pressed key first
+func TestKeyPressHistogram(t *testing.T) {
+	dir := withOutputDir(t)
+	if err := loadTestKeyNames(t, `{"0A": "Guide", "0B": "Info"}`); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	var lines []string
+	for i, key := range []string{"0B", "0A", "0B", "7F", "0B"} {
+		lines = append(lines, rawLine(t, "dev1", keyPressCode, start.Add(time.Duration(i)*time.Second), key))
+	}
+	fileName := writeInput(t, t.TempDir(), "a_MSO1.raw", lines...)
+	_, result := processPackages(t, testConfig(), []string{fileName})
+	saved := msoStats
+	defer func() { msoStats = saved }()
+	msoStats = result.msoStats
+	printKeyPressHistogram()
+
+	want := "mso, key, presses\nMSO1, Info, 3\nMSO1, 0x7F, 1\nMSO1, Guide, 1\n"
+	if got := readOutput(t, dir, keyPressHistogramFileName); got != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", keyPressHistogramFileName, got, want)
+	}
+}
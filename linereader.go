@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+)
+
+// Lines of an input file for the processing, all of them or the -head/-tail ones.
+// Blank lines are skipped, they are not events and do not count for -head and -tail.
+// -tail is a single pass: the last tailLines lines are kept in a ring buffer while
+// the file is read through, so the memory is bound by N lines, not by the file size.
+type LineReader struct {
+	scanner *bufio.Scanner
+	// Lines read from the file so far, the line number of the last one
+	lineNo int
+	// Lines handed out
+	count int
+	// Blank and whitespace only lines skipped
+	blank int
+
+	// -head and -tail, 0 is off
+	headLines int
+	tailLines int
+
+	tail       []lineJob
+	tailLoaded bool
+	tailNext   int
+}
+
+// lineNo is the number of the lines already read, e.g. the csv header
+func newLineReader(scanner *bufio.Scanner, lineNo int, headLines, tailLines int) *LineReader {
+	return &LineReader{scanner: scanner, lineNo: lineNo, headLines: headLines, tailLines: tailLines}
+}
+
+func (reader *LineReader) next() (lineJob, bool) {
+	if reader.headLines > 0 && reader.count >= reader.headLines {
+		return lineJob{}, false
+	}
+	if reader.tailLines > 0 {
+		if !reader.tailLoaded {
+			reader.loadTail()
+		}
+		if reader.tailNext >= len(reader.tail) {
+			return lineJob{}, false
+		}
+		job := reader.tail[reader.tailNext]
+		reader.tailNext++
+		reader.count++
+		return job, true
+	}
+
+	for {
+		start := startTiming()
+		scanned := reader.scanner.Scan()
+		addTiming(&timings.scan, start)
+		if !scanned {
+			return lineJob{}, false
+		}
+		reader.lineNo++
+		if isBlankLine(reader.scanner.Text()) {
+			reader.blank++
+			continue
+		}
+		reader.count++
+		return lineJob{reader.lineNo, reader.scanner.Text()}, true
+	}
+}
+
+func (reader *LineReader) loadTail() {
+	defer addTiming(&timings.scan, startTiming())
+	ring := make([]lineJob, 0, reader.tailLines)
+	oldest := 0
+	for reader.scanner.Scan() {
+		reader.lineNo++
+		if isBlankLine(reader.scanner.Text()) {
+			reader.blank++
+			continue
+		}
+		job := lineJob{reader.lineNo, reader.scanner.Text()}
+		if len(ring) < reader.tailLines {
+			ring = append(ring, job)
+		} else {
+			ring[oldest] = job
+			oldest = (oldest + 1) % reader.tailLines
+		}
+	}
+	reader.tail = append(ring[oldest:], ring[:oldest]...)
+	reader.tailLoaded = true
+}
+
+func (reader *LineReader) Err() error {
+	return reader.scanner.Err()
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
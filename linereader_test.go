@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readLines(input string, lineNo, head, tail int) []lineJob {
+	reader := newLineReader(newLineScanner(strings.NewReader(input)), lineNo, head, tail)
+	var jobs []lineJob
+	for job, ok := reader.next(); ok; job, ok = reader.next() {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func TestLineReaderHeadTail(t *testing.T) {
+	// The blank lines do not count, the line numbers are of the file
+	input := "one\n\ntwo\nthree\n  \nfour\nfive\n"
+	tests := []struct {
+		name   string
+		lineNo int
+		head   int
+		tail   int
+		want   []lineJob
+	}{
+		{"all", 0, 0, 0, []lineJob{{1, "one"}, {3, "two"}, {4, "three"}, {6, "four"}, {7, "five"}}},
+		{"head 2", 0, 2, 0, []lineJob{{1, "one"}, {3, "two"}}},
+		{"tail 2", 0, 0, 2, []lineJob{{6, "four"}, {7, "five"}}},
+		{"tail 3", 0, 0, 3, []lineJob{{4, "three"}, {6, "four"}, {7, "five"}}},
+		{"head over the lines", 0, 10, 0, []lineJob{{1, "one"}, {3, "two"}, {4, "three"}, {6, "four"}, {7, "five"}}},
+		{"tail over the lines", 0, 0, 10, []lineJob{{1, "one"}, {3, "two"}, {4, "three"}, {6, "four"}, {7, "five"}}},
+		{"after a header", 1, 1, 0, []lineJob{{2, "one"}}},
+	}
+	for _, test := range tests {
+		if got := readLines(input, test.lineNo, test.head, test.tail); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// -head and -tail of every file of the run
+func TestProcessHeadTail(t *testing.T) {
+	files := writeSpreadFiles(t, t.TempDir(), 3, 5, 50)
+	cfg := testConfig()
+	cfg.headLines = 10
+	_, result := processPackages(t, cfg, files)
+	if result.totalEvents != 30 {
+		t.Errorf("-head 10: %d lines of 3 files, want 30", result.totalEvents)
+	}
+	cfg.headLines, cfg.tailLines = 0, 7
+	_, result = processPackages(t, cfg, files)
+	if result.totalEvents != 21 {
+		t.Errorf("-tail 7: %d lines of 3 files, want 21", result.totalEvents)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Event code as listed by -list-codes
+type codeRecord struct {
+	Code       string `json:"code"`
+	Shortcut   string `json:"shortcut"`
+	Name       string `json:"name"`
+	Diagnostic bool   `json:"diagnostic"`
+}
+
+// Names are `X`Name, the letter in the backquotes is the shortcut
+func newCodeRecord(cmd Command) codeRecord {
+	record := codeRecord{Code: cmd.cmd, Name: cmd.name, Diagnostic: cmd.diagnostic}
+	if strings.HasPrefix(cmd.name, "`") {
+		if end := strings.Index(cmd.name[1:], "`"); end >= 0 {
+			record.Shortcut = cmd.name[1 : end+1]
+			record.Name = cmd.name[end+2:]
+		}
+	}
+	return record
+}
+
+// The commands list with the codes config applied, as a table or as json with -s json
+func printCodes(w io.Writer, format string) error {
+	records := make([]codeRecord, 0, len(commandsList))
+	for _, cmd := range commandsList {
+		records = append(records, newCodeRecord(cmd))
+	}
+
+	if format == jsonFormat {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	fmt.Fprintf(w, "%-5s %-9s %-11s %s\n", "Code", "Shortcut", "Diagnostic", "Name")
+	for _, record := range records {
+		diagnostic := ""
+		if record.Diagnostic {
+			diagnostic = "yes"
+		}
+		fmt.Fprintf(w, "%-5s %-9s %-11s %s\n", record.Code, record.Shortcut, diagnostic, record.Name)
+	}
+	_, err := fmt.Fprintf(w, "%d codes\n", len(records))
+	return err
+}
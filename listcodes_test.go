@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// All the 21 built-in codes, in the table and in the json
+func TestPrintCodes(t *testing.T) {
+	var table bytes.Buffer
+	if err := printCodes(&table, txtFormat); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 21+2 || lines[len(lines)-1] != "21 codes" {
+		t.Fatalf("table of %d lines, last %q, want 21 codes", len(lines), lines[len(lines)-1])
+	}
+	for _, want := range []string{
+		"41    A                     Ad Display",
+		"42    B         yes         Button Config",
+		"63    c                     Channel Change (brief)",
+		"5A    Z         yes         Menu Config.",
+	} {
+		if !strings.Contains(table.String(), want+"\n") {
+			t.Errorf("no %q row in:\n%s", want, table.String())
+		}
+	}
+
+	var records []codeRecord
+	var data bytes.Buffer
+	if err := printCodes(&data, jsonFormat); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 21 {
+		t.Fatalf("%d json codes, want 21", len(records))
+	}
+	if want := (codeRecord{"55", "U", "Unit Ident.", true}); records[17] != want {
+		t.Errorf("json code 17 %+v, want %+v", records[17], want)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+type LogLevel int
+
+const (
+	DEBUG LogLevel = iota
+	INFO
+	WARN
+	ERROR
+)
+
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (level LogLevel) String() string {
+	if level < DEBUG || level > ERROR {
+		return fmt.Sprintf("LEVEL(%d)", int(level))
+	}
+	return logLevelNames[level]
+}
+
+func parseLogLevel(name string) (LogLevel, error) {
+	for i, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return LogLevel(i), nil
+		}
+	}
+	return INFO, fmt.Errorf("Unknown log level: %s", name)
+}
+
+var (
+	logLevel = INFO
+	logger   = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func logAt(level LogLevel, format string, args ...interface{}) {
+	if level < logLevel {
+		return
+	}
+	logger.Printf("[%s] %s", level, fmt.Sprintf(format, args...))
+}
+
+func logDebug(format string, args ...interface{}) {
+	logAt(DEBUG, format, args...)
+}
+
+func logInfo(format string, args ...interface{}) {
+	logAt(INFO, format, args...)
+}
+
+func logWarn(format string, args ...interface{}) {
+	logAt(WARN, format, args...)
+}
+
+func logError(format string, args ...interface{}) {
+	logAt(ERROR, format, args...)
+}
+
+func debugEnabled() bool {
+	return logLevel <= DEBUG
+}
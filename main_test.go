@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The flags are parsed in init(), the test flags have to be defined before it
+var _ = func() bool {
+	testing.Init()
+	return true
+}()
+
+// Raw input line of the event, the payload in hex
+func rawLine(t *testing.T, deviceId, code string, timestamp time.Time, payload string) string {
+	t.Helper()
+	clickString, err := encodeEvent(testConfig(), code, timestamp, payload)
+	if err != nil {
+		t.Fatalf("encodeEvent(%s, %v): %v", code, timestamp, err)
+	}
+	return deviceId + " " + clickString
+}
+
+// Writes the lines into the dir/name input file
+func writeInput(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	fileName := filepath.Join(dir, name)
+	if err := os.WriteFile(fileName, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// The generated sample of testdata/generate.go
+const fixtureFileName = "testdata/sample_MSO1.raw"
+
+func fixtureLines(tb testing.TB) []string {
+	tb.Helper()
+	data, err := os.ReadFile(fixtureFileName)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// Config of a reproducible run: zero initial fill, no event logs
+func testConfig() *Config {
+	cfg := newConfig()
+	cfg.initBuffer = initBufferZero
+	return cfg
+}
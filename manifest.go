@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Reads the input file paths from the manifest, one path per line.
+// Blank lines and # comments are skipped, missing files are reported and dropped.
+// An archive path is taken as its entries, a single entry as archive.zip!entry.
+func readManifest(manifestFileName string) []string {
+	file, err := os.Open(manifestFileName)
+	if err != nil {
+		logError("Error opening manifest: %v", err)
+		exit(-1)
+	}
+	defer file.Close()
+
+	fileList := []string{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" || strings.HasPrefix(path, "#") {
+			continue
+		}
+		if _, err := statInput(path); err != nil {
+			logWarn("Manifest %s line %d: %v", manifestFileName, lineNo, err)
+			continue
+		}
+		for _, input := range expandArchive(path) {
+			fileList = append(fileList, input)
+			logDebug("Added from manifest: %s", input)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logError("Error reading manifest: %v", err)
+		exit(-1)
+	}
+	return fileList
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout of the %v formatted timestamps of the csv outputs
+const outputTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+const (
+	// timestamp, events
+	eventsPerSecondColumns = 2
+	// timestamp, deviceId, eventCode
+	packageColumns = 3
+	// timestamp, deviceId, eventCode, mso
+	packageMsoColumns = 4
+)
+
+// Rows of a prior csv output, packages or events per second
+type mergeInput struct {
+	fileName string
+	columns  int
+	rows     [][]string
+}
+
+func readMergeInput(fileName string) (mergeInput, error) {
+	input := mergeInput{fileName: fileName}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return input, err
+	}
+	defer file.Close()
+
+	scanner := newLineScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ", ")
+		if input.columns == 0 {
+			input.columns = len(fields)
+		}
+		if len(fields) != input.columns {
+			return input, fmt.Errorf("%s:%d: %d columns, expected %d", fileName, lineNo, len(fields), input.columns)
+		}
+		input.rows = append(input.rows, fields)
+	}
+	if err = scanner.Err(); err != nil {
+		return input, fmt.Errorf("Error reading %s: %v", fileName, err)
+	}
+	switch input.columns {
+	case 0, eventsPerSecondColumns, packageColumns, packageMsoColumns:
+	default:
+		return input, fmt.Errorf("%s: %d columns, not a packages or events per second csv", fileName, input.columns)
+	}
+	return input, nil
+}
+
+// Merges the packages or events per second csv outputs of earlier runs into the
+// sorted package file and re-aggregated per second files, without parsing the raw files again.
+// All the inputs must have the same columns, empty files are skipped.
+func mergeOutputs(files []string) (int, error) {
+	if len(files) == 0 {
+		return 0, fmt.Errorf("No files to merge")
+	}
+
+	var inputs []mergeInput
+	for _, fileName := range files {
+		input, err := readMergeInput(fileName)
+		if err != nil {
+			return 0, err
+		}
+		if input.columns == 0 {
+			logWarn("Empty file skipped: %s", fileName)
+			continue
+		}
+		if len(inputs) > 0 && input.columns != inputs[0].columns {
+			return 0, fmt.Errorf("Schema mismatch: %s has %d columns, %s has %d",
+				fileName, input.columns, inputs[0].fileName, inputs[0].columns)
+		}
+		inputs = append(inputs, input)
+	}
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("Nothing to merge, all the files are empty")
+	}
+
+	if inputs[0].columns == eventsPerSecondColumns {
+		return mergeEventsPerSecond(inputs)
+	}
+	return mergePackages(inputs)
+}
+
+func mergePackages(inputs []mergeInput) (int, error) {
+	var packages PackageList
+	for _, input := range inputs {
+		for i, fields := range input.rows {
+			timestamp, err := time.Parse(outputTimeLayout, fields[0])
+			if err != nil {
+				return 0, fmt.Errorf("%s: row %d: %v", input.fileName, i+1, err)
+			}
+			mso := unknownMso
+			if input.columns == packageMsoColumns {
+				mso = fields[3]
+			}
+			packages = append(packages, Package{timestamp, fields[1], fields[2], mso})
+		}
+	}
+	// Keep the mso column of the inputs
+	msoColumn = inputs[0].columns == packageMsoColumns
+
+	printOutputFile(packages)
+	printEventsPerSecond(packages, "eventsPerSecond")
+	return len(packages), nil
+}
+
+func mergeEventsPerSecond(inputs []mergeInput) (int, error) {
+	eventsPerSecond := make(map[time.Time]int)
+	for _, input := range inputs {
+		for i, fields := range input.rows {
+			timestamp, err := time.Parse(outputTimeLayout, fields[0])
+			if err != nil {
+				return 0, fmt.Errorf("%s: row %d: %v", input.fileName, i+1, err)
+			}
+			numberOfEvents, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, fmt.Errorf("%s: row %d: wrong number of events: %v", input.fileName, i+1, err)
+			}
+			// Same second from the different inputs adds up, whatever the time zone
+			eventsPerSecond[timestamp.UTC()] += numberOfEvents
+		}
+	}
+
+	var orderedEventsPerSecond TimepointTypeList
+	for timestamp, numberOfEvents := range eventsPerSecond {
+		orderedEventsPerSecond = append(orderedEventsPerSecond, TimepointType{timestamp, numberOfEvents})
+	}
+	sort.Sort(orderedEventsPerSecond)
+
+	printTimepoints(orderedEventsPerSecond, "eventsPerSecond")
+	return len(orderedEventsPerSecond), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func withCsvOutput(t *testing.T) string {
+	t.Helper()
+	dir := withOutputDir(t)
+	savedFormat, savedExt, savedName, savedColumn := outputFormat, outputExtensionOverride, outputFileName, msoColumn
+	t.Cleanup(func() {
+		outputFormat, outputExtensionOverride, outputFileName, msoColumn = savedFormat, savedExt, savedName, savedColumn
+	})
+	outputFormat, outputExtensionOverride, outputFileName = csvFormat, "", "output"
+	return dir
+}
+
+// Two package files of earlier runs, sorted together and counted per second again
+func TestMergePackages(t *testing.T) {
+	dir := withCsvOutput(t)
+	input := t.TempDir()
+	first := writeInput(t, input, "east.csv",
+		"2016-03-01 20:00:01 +0000 UTC, dev2, Pulse",
+		"2016-03-01 20:00:03 +0000 UTC, dev1, Lock")
+	second := writeInput(t, input, "west.csv",
+		"2016-03-01 20:00:01 +0000 UTC, dev1, Pulse",
+		"",
+		"2016-03-01 20:00:02 +0000 UTC, dev3, Pulse")
+	merged, err := mergeOutputs([]string{first, second})
+	if err != nil || merged != 4 {
+		t.Fatalf("mergeOutputs = %d, %v, want 4", merged, err)
+	}
+
+	want := "2016-03-01 20:00:01 +0000 UTC, dev1, Pulse\n" +
+		"2016-03-01 20:00:01 +0000 UTC, dev2, Pulse\n" +
+		"2016-03-01 20:00:02 +0000 UTC, dev3, Pulse\n" +
+		"2016-03-01 20:00:03 +0000 UTC, dev1, Lock\n"
+	if got := readOutput(t, dir, "output.csv"); got != want {
+		t.Errorf("output.csv:\n%s\nwant:\n%s", got, want)
+	}
+	perSecond := readOutput(t, dir, "eventsPerSecond-2016-03-01.csv")
+	if !strings.Contains(perSecond, "2016-03-01 20:00:01 +0000 UTC, 2\n") {
+		t.Errorf("eventsPerSecond-2016-03-01.csv:\n%s\nwant 2 packages at 20:00:01", perSecond)
+	}
+}
+
+func TestMergeEventsPerSecond(t *testing.T) {
+	dir := withCsvOutput(t)
+	input := t.TempDir()
+	first := writeInput(t, input, "a.csv",
+		"2016-03-01 20:00:01 +0000 UTC, 3",
+		"2016-03-01 20:00:02 +0000 UTC, 1")
+	second := writeInput(t, input, "b.csv",
+		"2016-03-01 15:00:01 -0500 EST, 2")
+	merged, err := mergeOutputs([]string{first, second})
+	if err != nil || merged != 2 {
+		t.Fatalf("mergeOutputs = %d, %v, want 2 seconds", merged, err)
+	}
+	content := readOutput(t, dir, "eventsPerSecond-2016-03-01.csv")
+	if !strings.Contains(content, "20:00:01 +0000 UTC, 5\n") {
+		t.Errorf("eventsPerSecond-2016-03-01.csv:\n%s\nwant the 5 events of the same second", content)
+	}
+}
+
+func TestMergeSchemaMismatch(t *testing.T) {
+	withCsvOutput(t)
+	input := t.TempDir()
+	packages := writeInput(t, input, "packages.csv", "2016-03-01 20:00:01 +0000 UTC, dev1, Pulse")
+	perSecond := writeInput(t, input, "eventsPerSecond.csv", "2016-03-01 20:00:01 +0000 UTC, 3")
+	odd := writeInput(t, input, "odd.csv", "2016-03-01 20:00:01 +0000 UTC, dev1, Pulse", "2016-03-01 20:00:02 +0000 UTC, dev1")
+	for _, files := range [][]string{{packages, perSecond}, {odd}, {filepath.Join(input, "missing.csv")}, {}} {
+		if _, err := mergeOutputs(files); err == nil {
+			t.Errorf("mergeOutputs(%v), want an error", files)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+type metric struct {
+	name       string
+	metricType string
+	help       string
+	value      int
+}
+
+func writeMetric(w io.Writer, m metric) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.metricType)
+	fmt.Fprintf(w, "%s %d\n", m.name, m.value)
+}
+
+// Per MSO family, one sample per MSO label
+func writeMsoMetric(w io.Writer, name, metricType, help string, value func(*MsoStats) int) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	for _, mso := range sortedMsoNames() {
+		fmt.Fprintf(w, "%s{mso=%s} %d\n", name, quoteLabel(mso), value(msoStats[mso]))
+	}
+}
+
+// Escapes backslash, double quote and new line as the text format requires
+func quoteLabel(value string) string {
+	return strconv.Quote(value)
+}
+
+// Prometheus text format dump of the run summary
+func printMetrics(fileName string, totalEvents, totalPackages, parseErrors, devices, maxPerSecond int) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+
+	w := bufio.NewWriter(file)
+	for _, m := range []metric{
+		{"csba_total_events", "counter", "Total number of input lines processed.", totalEvents},
+		{"csba_total_packages", "counter", "Total number of simulated packages sent.", totalPackages},
+		{"csba_parse_errors", "counter", "Total number of lines failed to parse.", parseErrors},
+		{"csba_devices", "gauge", "Number of distinct devices.", devices},
+		{"csba_max_events_per_second", "gauge", "Max number of packages sent within one second.", maxPerSecond},
+	} {
+		writeMetric(w, m)
+	}
+
+	if len(msoStats) > 0 {
+		writeMsoMetric(w, "csba_mso_events", "counter", "Valid events per MSO.",
+			func(stats *MsoStats) int { return stats.events })
+		writeMsoMetric(w, "csba_mso_packages", "counter", "Simulated packages per MSO.",
+			func(stats *MsoStats) int { return stats.packages })
+		writeMsoMetric(w, "csba_mso_devices", "gauge", "Number of distinct devices per MSO.",
+			func(stats *MsoStats) int { return len(stats.devices) })
+		writeMsoMetric(w, "csba_mso_max_events_per_second", "gauge", "Max number of packages sent within one second per MSO.",
+			func(stats *MsoStats) int { return stats.maxPerSecond().numberOfEvents })
+	}
+	w.Flush()
+	file.Close()
+}
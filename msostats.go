@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const summaryByMsoFileName = "summary-by-mso.csv"
+
+// Per MSO rollup of the simulation results
+type MsoStats struct {
+	events          int
+	packages        int
+	devices         map[string]bool
+	eventsPerSecond map[time.Time]int
+	// Valid events by the (merged) event name
+	codes map[string]int
+	// Key presses by the key name, with -keys
+	keys map[string]int
+}
+
+var msoStats = make(map[string]*MsoStats)
+
+func newMsoStats() *MsoStats {
+	return &MsoStats{
+		devices:         make(map[string]bool),
+		eventsPerSecond: make(map[time.Time]int),
+		codes:           make(map[string]int),
+		keys:            make(map[string]int),
+	}
+}
+
+func getMsoStats(mso string) *MsoStats {
+	return msoStatsOf(msoStats, mso)
+}
+
+// Stats of the mso in the rollup, added when missing
+func msoStatsOf(rollup map[string]*MsoStats, mso string) *MsoStats {
+	stats, ok := rollup[mso]
+	if !ok {
+		stats = newMsoStats()
+		rollup[mso] = stats
+	}
+	return stats
+}
+
+func (stats *MsoStats) merge(other *MsoStats) {
+	stats.events += other.events
+	stats.packages += other.packages
+	for deviceId := range other.devices {
+		stats.devices[deviceId] = true
+	}
+	for timestamp, numberOfEvents := range other.eventsPerSecond {
+		stats.eventsPerSecond[timestamp] += numberOfEvents
+	}
+	for eventCode, numberOfEvents := range other.codes {
+		stats.codes[eventCode] += numberOfEvents
+	}
+	for key, presses := range other.keys {
+		stats.keys[key] += presses
+	}
+}
+
+func (stats *MsoStats) addEvent(deviceId, eventCode string) {
+	stats.events++
+	stats.codes[histogramEvent(eventCode)]++
+	stats.devices[deviceId] = true
+}
+
+func (stats *MsoStats) addPackage(pkg Package) {
+	stats.packages++
+	stats.eventsPerSecond[pkg.timestamp]++
+}
+
+// Busiest second, the earliest one wins on ties
+func (stats *MsoStats) maxPerSecond() (max TimepointType) {
+	for timestamp, numberOfEvents := range stats.eventsPerSecond {
+		if numberOfEvents > max.numberOfEvents ||
+			(numberOfEvents == max.numberOfEvents && timestamp.Before(max.timestamp)) {
+			max = TimepointType{timestamp, numberOfEvents}
+		}
+	}
+	return
+}
+
+func sortedMsoNames() []string {
+	names := make([]string, 0, len(msoStats))
+	for mso := range msoStats {
+		names = append(names, mso)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func printSummaryByMso() {
+	if len(msoStats) == 0 {
+		return
+	}
+
+	w, err := createOutputFile(summaryByMsoFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "mso, events, packages, devices, maxPerSecond, maxAt")
+	fmt.Println("Per MSO:")
+	for _, mso := range sortedMsoNames() {
+		stats := msoStats[mso]
+		max := stats.maxPerSecond()
+		fmt.Fprintf(w, "%s, %d, %d, %d, %d, %v\n",
+			mso, stats.events, stats.packages, len(stats.devices), max.numberOfEvents, max.timestamp)
+		fmt.Printf("\t%s:\t events: %d\t packages: %d\t devices: %d\t max per second: %d\n",
+			mso, stats.events, stats.packages, len(stats.devices), max.numberOfEvents)
+	}
+	w.Close()
+}
+
+func packagesByMso(packages PackageList) map[string]PackageList {
+	byMso := make(map[string]PackageList)
+	for _, pkg := range packages {
+		byMso[pkg.mso] = append(byMso[pkg.mso], pkg)
+	}
+	return byMso
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const gzipExt = ".gz"
+
+// Buffered output file. A rolling one goes over to name-part2.ext, name-part3.ext, ...
+// once maxFileSize bytes are written. Every Write is kept whole in one part,
+// so the rows written with a single Fprintf never get split.
+// With -gzip-out the content is compressed and .gz is appended to the name,
+// the size limit still counts the uncompressed bytes.
+// With -append an existing file is added to instead of truncated, a gzip one gets
+// another gzip member, and the content already there counts for the size limit.
+// The appends of a single run never share a file, two runs appending to the same
+// -outdir at the same time may interleave their lines.
+type OutputFile struct {
+	name    string
+	rolling bool
+	part    int
+	file    *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	written int64
+	// Opened for -append with some content already there
+	appended bool
+}
+
+// -outdir is created when missing, the run can not go on without it
+func makeOutputDir() {
+	if outputDir == "" {
+		return
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		logError("Error creating output directory: %v", err)
+		exit(-1)
+	}
+}
+
+// The daily prefix-YYYY-MM-DD.csv files of a rolling output, one open at a time.
+// The rows come in the time order, a day coming back after another one anyway
+// is reopened and added to instead of truncated.
+type DayFiles struct {
+	prefix  string
+	current *OutputFile
+	// Every day file of the run, closed but the current one
+	files map[string]*OutputFile
+}
+
+func newDayFiles(prefix string) *DayFiles {
+	return &DayFiles{prefix: prefix, files: make(map[string]*OutputFile)}
+}
+
+// File of the timestamp day, the single one with -flatten
+func (days *DayFiles) writer(timestamp time.Time) *OutputFile {
+	year, month, day := timestamp.Date()
+	name := formateCurrentFileName(days.prefix, year, month, day)
+	if days.current != nil && days.current.name == name {
+		return days.current
+	}
+	days.Close()
+
+	w, ok := days.files[name]
+	var err error
+	if ok {
+		err = w.reopen()
+	} else {
+		w, err = createRollingOutputFile(name)
+		days.files[name] = w
+	}
+	if err != nil {
+		logError("%v", err)
+	}
+	days.current = w
+	return w
+}
+
+func (days *DayFiles) Close() {
+	if days.current == nil {
+		return
+	}
+	if err := days.current.Close(); err != nil {
+		logError("Error writing %s: %v", days.current.name, err)
+	}
+	days.current = nil
+}
+
+// The returned file is never nil, on error its writes fail like the ones of a nil *os.File
+func createOutputFile(name string) (*OutputFile, error) {
+	out := &OutputFile{name: name, part: 1}
+	err := out.open(name, appendOutput)
+	return out, err
+}
+
+// Line oriented output, split into parts with -max-file-size
+func createRollingOutputFile(name string) (*OutputFile, error) {
+	out, err := createOutputFile(name)
+	out.rolling = true
+	return out, err
+}
+
+func (out *OutputFile) open(name string, appending bool) error {
+	if gzipOutput {
+		name += gzipExt
+	}
+	path := filepath.Join(outputDir, name)
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	out.appended = false
+	out.written = 0
+	if appending {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			// The content already there counts for -max-file-size, compressed for a gzip one
+			out.appended = true
+			out.written = info.Size()
+		}
+	}
+	file, err := os.OpenFile(path, mode, 0666)
+	out.file = file
+	if gzipOutput && err == nil {
+		out.gz = gzip.NewWriter(file)
+		out.w = bufio.NewWriter(out.gz)
+	} else {
+		out.gz = nil
+		out.w = bufio.NewWriter(file)
+	}
+	return err
+}
+
+func (out *OutputFile) partName() string {
+	ext := filepath.Ext(out.name)
+	return fmt.Sprintf("%s-part%d%s", strings.TrimSuffix(out.name, ext), out.part, ext)
+}
+
+func (out *OutputFile) rotate() error {
+	if err := out.close(); err != nil {
+		return err
+	}
+	out.part++
+	name := out.partName()
+	logDebug("New filename: %s", name)
+	return out.open(name, appendOutput)
+}
+
+// Opens the current part again after Close, to add to it. Its rows are already
+// counted in written.
+func (out *OutputFile) reopen() error {
+	name := out.name
+	if out.part > 1 {
+		name = out.partName()
+	}
+	written := out.written
+	err := out.open(name, true)
+	out.written = written
+	return err
+}
+
+func (out *OutputFile) Write(p []byte) (int, error) {
+	// The parts left full by a previous -append run are passed over
+	for out.rolling && maxFileSize > 0 && out.written > 0 && out.written+int64(len(p)) > maxFileSize {
+		if err := out.rotate(); err != nil {
+			logError("%v", err)
+			break
+		}
+	}
+	n, err := out.w.Write(p)
+	out.written += int64(n)
+	return n, err
+}
+
+func (out *OutputFile) close() error {
+	err := out.w.Flush()
+	if out.gz != nil {
+		if gzErr := out.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if out.file != nil {
+		if closeErr := out.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Whether w is an output file appended to, its header is already there
+func isAppended(w io.Writer) bool {
+	out, ok := w.(*OutputFile)
+	return ok && out.appended
+}
+
+// Header line of a new output file, left out when appending to an existing one
+func writeHeader(w io.Writer, header string) {
+	if !isAppended(w) {
+		fmt.Fprintln(w, header)
+	}
+}
+
+func (out *OutputFile) Close() error {
+	return out.close()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Output files go into a temporary -outdir for the test
+func withOutputDir(t *testing.T) string {
+	t.Helper()
+	dir, saved := t.TempDir(), outputDir
+	outputDir = dir
+	t.Cleanup(func() { outputDir = saved })
+	return dir
+}
+
+func readOutput(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDayFilesOneOpenAtATime(t *testing.T) {
+	dir := withOutputDir(t)
+	days := newDayFiles("test")
+	first := time.Date(2016, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(days.writer(first.AddDate(0, 0, i)), "day %d\n", i)
+		if i > 0 {
+			// Closed and flushed on the day change
+			name := fmt.Sprintf("test-2016-03-%02d.csv", i)
+			if got, want := readOutput(t, dir, name), fmt.Sprintf("day %d\n", i-1); got != want {
+				t.Fatalf("%s after the day change: %q, want %q", name, got, want)
+			}
+		}
+	}
+	// A day coming back is added to
+	fmt.Fprintln(days.writer(first.Add(time.Hour)), "day 0 again")
+	days.Close()
+
+	if got, want := readOutput(t, dir, "test-2016-03-01.csv"), "day 0\nday 0 again\n"; got != want {
+		t.Errorf("revisited day file: %q, want %q", got, want)
+	}
+	if got, want := readOutput(t, dir, "test-2016-03-05.csv"), "day 4\n"; got != want {
+		t.Errorf("last day file: %q, want %q", got, want)
+	}
+}
+
+// The content of the previous runs counts for -max-file-size with -append
+func TestAppendRollingOutputSize(t *testing.T) {
+	dir := withOutputDir(t)
+	savedAppend, savedMax := appendOutput, maxFileSize
+	appendOutput, maxFileSize = true, 15
+	defer func() { appendOutput, maxFileSize = savedAppend, savedMax }()
+
+	for _, name := range []string{"out.csv", "out-part2.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("previous\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := createRollingOutputFile("out.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(out, "abc\n")
+	fmt.Fprint(out, "new row\n")
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"out.csv", "previous\nabc\n"},
+		{"out-part2.csv", "previous\n"},
+		{"out-part3.csv", "new row\n"},
+	}
+	for _, test := range tests {
+		if got := readOutput(t, dir, test.name); got != test.want {
+			t.Errorf("%s: %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// Every row kept whole, a part holds the rows up to the limit
+func TestRollingOutputRollover(t *testing.T) {
+	dir := withOutputDir(t)
+	saved := maxFileSize
+	maxFileSize = 20
+	defer func() { maxFileSize = saved }()
+
+	out, err := createRollingOutputFile("events.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(out, "row %d, 0123\n", i)
+	}
+	// Over the limit alone, in a part of its own
+	fmt.Fprint(out, "a row over the limit\n")
+	fmt.Fprint(out, "last\n")
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"events.csv", "row 0, 0123\n"},
+		{"events-part2.csv", "row 1, 0123\n"},
+		{"events-part5.csv", "row 4, 0123\n"},
+		{"events-part6.csv", "a row over the limit\n"},
+		{"events-part7.csv", "last\n"},
+	}
+	for _, test := range tests {
+		if got := readOutput(t, dir, test.name); got != test.want {
+			t.Errorf("%s: %q, want %q", test.name, got, test.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events-part8.csv")); err == nil {
+		t.Error("events-part8.csv written, want 7 parts")
+	}
+}
+
+// -gzip-out: name.gz, decompressed to the rows written, the -append ones as another member
+func TestGzipOutput(t *testing.T) {
+	dir := withOutputDir(t)
+	savedGzip, savedAppend := gzipOutput, appendOutput
+	gzipOutput = true
+	defer func() { gzipOutput, appendOutput = savedGzip, savedAppend }()
+
+	for run, row := range []string{"a, 1\n", "b, 2\n"} {
+		appendOutput = run > 0
+		out, err := createOutputFile("output.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeHeader(out, "name, value")
+		fmt.Fprint(out, row)
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(dir, "output.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "name, value\na, 1\nb, 2\n"; string(content) != want {
+		t.Errorf("output.csv.gz: %q, want %q", content, want)
+	}
+}
+
+// The second run's events after the first run's, the header written once
+func TestAppendSecondRun(t *testing.T) {
+	dir := withOutputDir(t)
+	saved := appendOutput
+	defer func() { appendOutput = saved }()
+
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	runs := []PackageList{
+		{Pack(start, "dev1", "Pulse", "MSO1")},
+		{Pack(start.Add(time.Second), "dev2", "Pulse", "MSO1"), Pack(start.Add(time.Second), "dev1", "Lock", "MSO1")},
+	}
+	for run, packages := range runs {
+		appendOutput = run > 0
+		printEventsPerSecond(packages, "eventsPerSecond")
+		printTopSeconds(packages, 1)
+	}
+
+	want := "2016-03-01 20:00:00 +0000 UTC, 1\n2016-03-01 20:00:01 +0000 UTC, 2\n"
+	if got := readOutput(t, dir, "eventsPerSecond-2016-03-01.csv"); got != want {
+		t.Errorf("eventsPerSecond-2016-03-01.csv:\n%s\nwant:\n%s", got, want)
+	}
+	if got := readOutput(t, dir, topSecondsFileName); got != "timestamp, events\n"+want {
+		t.Errorf("%s:\n%s\nwant the header once", topSecondsFileName, got)
+	}
+
+	appendOutput = false
+	printEventsPerSecond(runs[1], "eventsPerSecond")
+	if got, want := readOutput(t, dir, "eventsPerSecond-2016-03-01.csv"), "2016-03-01 20:00:01 +0000 UTC, 2\n"; got != want {
+		t.Errorf("without -append:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	csvFormat  = "csv"
+	txtFormat  = "txt"
+	jsonFormat = "json"
+	xmlFormat  = "xml"
+
+	txtTimeLayout = "2006-01-02 15:04:05 MST"
+)
+
+// Output file extension by the format, when -ext is not given
+var defaultExtensions = map[string]string{
+	csvFormat:  "csv",
+	txtFormat:  "txt",
+	jsonFormat: "json",
+	xmlFormat:  "xml",
+}
+
+func isKnownFormat(format string) bool {
+	_, ok := defaultExtensions[format]
+	return ok
+}
+
+func outputExtension() string {
+	if outputExtensionOverride != "" {
+		return outputExtensionOverride
+	}
+	return defaultExtensions[outputFormat]
+}
+
+// Package as it is serialized into the json and xml outputs
+type packageRecord struct {
+	XMLName   xml.Name  `json:"-" xml:"package"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp,attr"`
+	DeviceId  string    `json:"deviceId" xml:"deviceId,attr"`
+	EventCode string    `json:"eventCode" xml:"eventCode,attr"`
+	Mso       string    `json:"mso,omitempty" xml:"mso,attr,omitempty"`
+}
+
+func newPackageRecord(pkg Package) packageRecord {
+	record := packageRecord{Timestamp: pkg.timestamp, DeviceId: pkg.deviceId, EventCode: pkg.eventCode}
+	if msoColumn {
+		record.Mso = pkg.mso
+	}
+	return record
+}
+
+func writePackages(w io.Writer, packages PackageList) error {
+	switch outputFormat {
+	case jsonFormat:
+		records := make([]packageRecord, 0, len(packages))
+		for _, pkg := range packages {
+			records = append(records, newPackageRecord(pkg))
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case xmlFormat:
+		fmt.Fprint(w, xml.Header)
+		fmt.Fprintln(w, "<packages>")
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("  ", "  ")
+		for _, pkg := range packages {
+			if err := encoder.Encode(newPackageRecord(pkg)); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w, "\n</packages>")
+		return err
+	case txtFormat:
+		// Human readable aligned columns
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		if !isAppended(w) {
+			if msoColumn {
+				fmt.Fprintln(tw, "Timestamp\tDevice\tEvent\tMSO")
+			} else {
+				fmt.Fprintln(tw, "Timestamp\tDevice\tEvent")
+			}
+		}
+		for _, pkg := range packages {
+			fmt.Fprintf(tw, "%s\t%s\t%s", pkg.timestamp.Format(txtTimeLayout), pkg.deviceId, pkg.eventCode)
+			if msoColumn {
+				fmt.Fprintf(tw, "\t%s", pkg.mso)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	default:
+		for _, pkg := range packages {
+			if _, err := fmt.Fprintln(w, pkg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPackages() PackageList {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	return PackageList{
+		Pack(start, "0000000001", "Pulse", "MSO1"),
+		Pack(start.Add(90*time.Second), "00000000000000A2", "Channel Change (verbose)", "MSO1"),
+	}
+}
+
+// The file name of every format and -ext, the content by the format alone
+func TestOutputFormatExtension(t *testing.T) {
+	dir := withOutputDir(t)
+	savedFormat, savedExt, savedName := outputFormat, outputExtensionOverride, outputFileName
+	defer func() { outputFormat, outputExtensionOverride, outputFileName = savedFormat, savedExt, savedName }()
+
+	prefixes := map[string]string{
+		csvFormat:  "2016-03-01 20:00:00 +0000 UTC, 0000000001, Pulse",
+		txtFormat:  "Timestamp",
+		jsonFormat: "[",
+		xmlFormat:  "<?xml",
+	}
+	for _, format := range []string{csvFormat, txtFormat, jsonFormat, xmlFormat} {
+		for _, ext := range []string{"", "dat", "csv"} {
+			outputFormat, outputExtensionOverride, outputFileName = format, ext, "output-"+format
+			printOutputFile(testPackages())
+
+			want := "output-" + format + "." + defaultExtensions[format]
+			if ext != "" {
+				want = "output-" + format + "." + ext
+			}
+			content := readOutput(t, dir, want)
+			if !strings.HasPrefix(content, prefixes[format]) {
+				t.Errorf("-s %s -ext %q: %s starts with %q, want %q", format, ext, want, content[:20], prefixes[format])
+			}
+			os.Remove(filepath.Join(dir, want))
+		}
+	}
+}
+
+// The aligned columns of -s txt, against testdata/packages.golden.txt
+func TestWritePackagesTxtGolden(t *testing.T) {
+	savedFormat, savedColumn := outputFormat, msoColumn
+	defer func() { outputFormat, msoColumn = savedFormat, savedColumn }()
+	outputFormat, msoColumn = txtFormat, true
+
+	var out strings.Builder
+	if err := writePackages(&out, testPackages()); err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile("testdata/packages.golden.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(golden) {
+		t.Errorf("txt output:\n%s\nwant:\n%s", out.String(), golden)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Lines of the fixture, taken through the parser as in a run
+func BenchmarkParseEvent(b *testing.B) {
+	lines := fixtureLines(b)
+	cfg := testConfig()
+	source := LineSource{"MSO1", fixtureFileName, 0}
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, _, _, err := parseEvent(cfg, lines[i%len(lines)], nil, source, now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The whole per line step, the buffer simulation included
+func BenchmarkProcessLine(b *testing.B) {
+	lines := fixtureLines(b)
+	cfg := testConfig()
+	result := FileResult{fileName: fixtureFileName, msoStats: newMsoStats(), run: &processRun{}}
+	buffers := newBufferState()
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result.processLine(cfg, lines[i%len(lines)], i+1, "MSO1", nil, buffers, now)
+		if len(result.packages) > 1000 {
+			result.packages = result.packages[:0]
+		}
+	}
+}
+
+// Index based tokens, no per line allocations in the parser
+func TestParseEventAllocations(t *testing.T) {
+	lines := fixtureLines(t)
+	cfg := testConfig()
+	source := LineSource{"MSO1", fixtureFileName, 0}
+	now := time.Now()
+	i := 0
+	allocs := testing.AllocsPerRun(len(lines), func() {
+		parseEvent(cfg, lines[i%len(lines)], nil, source, now)
+		i++
+	})
+	if allocs != 0 {
+		t.Errorf("parseEvent allocates %.1f times per line", allocs)
+	}
+}
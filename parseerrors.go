@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Parse errors, the messages carry the offending value after the colon
+var (
+	errWrongLineFormat = errors.New("Wrong line format")
+	errUnknownCode     = errors.New("Unknown Clickstream Code")
+	errParserTime      = errors.New("Parser time exception")
+	errWrongTimestamp  = errors.New("Wrong timestamp")
+	errWrongDate       = errors.New("Wrong date")
+	errWrongEventSize  = errors.New("Wrong event size")
+)
+
+type ErrorCategory int
+
+const (
+	// Lines split into the wrong tokens or columns
+	formatError ErrorCategory = iota
+	// Event codes not in the commands list
+	codeError
+	// Timestamps that do not decode
+	timestampError
+	// Decoded timestamps out of the accepted range
+	dateError
+	// Panics recovered in the parser, in any of the fields
+	panicError
+	// Files that could not be opened or read
+	readError
+)
+
+var errorCategoryNames = []string{"format", "code", "timestamp", "date", "panic", "read"}
+
+func (category ErrorCategory) String() string {
+	if category < formatError || category > readError {
+		return fmt.Sprintf("CATEGORY(%d)", int(category))
+	}
+	return errorCategoryNames[category]
+}
+
+// Category of an error log entry, the errors not from the parser are read errors
+func errorCategory(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, errWrongLineFormat), errors.Is(err, errWrongEventSize):
+		return formatError
+	case errors.Is(err, errUnknownCode):
+		return codeError
+	case errors.Is(err, errWrongTimestamp):
+		return timestampError
+	case errors.Is(err, errWrongDate):
+		return dateError
+	case errors.Is(err, errParserTime):
+		return panicError
+	}
+	return readError
+}
+
+func newErrorLogEntry(fileName string, lineNo int, line string, err error) ErrorLogEntry {
+	return ErrorLogEntry{fileName, lineNo, line, err, errorCategory(err)}
+}
+
+// Error log entries by category, all the categories are present
+func countErrorsByCategory(entries []ErrorLogEntry) map[string]int {
+	counts := make(map[string]int, len(errorCategoryNames))
+	for _, name := range errorCategoryNames {
+		counts[name] = 0
+	}
+	for _, entry := range entries {
+		counts[entry.category.String()]++
+	}
+	return counts
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCategory
+	}{
+		{errWrongLineFormat, formatError},
+		{fmt.Errorf("%w: 12 bytes", errWrongEventSize), formatError},
+		{errUnknownCode, codeError},
+		{fmt.Errorf("%w: ZZZZ", errWrongTimestamp), timestampError},
+		{fmt.Errorf("%w: 1970-01-01", errWrongDate), dateError},
+		{fmt.Errorf("%w: panic parsing the payload: out of range", errParserTime), panicError},
+		{errors.New("open x.raw: no such file"), readError},
+	}
+	for _, test := range tests {
+		if got := errorCategory(test.err); got != test.want {
+			t.Errorf("errorCategory(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+// The recovered panic keeps the field parsed and goes into its own category
+func TestParseEventPanic(t *testing.T) {
+	cfg := testConfig()
+	cfg.eventSequenceLog = true
+	eventLogChan := make(chan EventLogEntry)
+	close(eventLogChan)
+	line := rawLine(t, "0000000001", "50", time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC), "")
+	now := time.Now()
+	timestamp, _, _, _, _, err := parseEvent(cfg, line, eventLogChan, LineSource{"MSO1", "panic.raw", 1}, now)
+	if err == nil || !strings.Contains(err.Error(), "panic parsing the payload") {
+		t.Fatalf("parseEvent with a closed event log = %v, want the panic parsing the payload", err)
+	}
+	if !timestamp.Equal(now) {
+		t.Errorf("timestamp = %v, want the now %v", timestamp, now)
+	}
+	if category := errorCategory(err); category != panicError {
+		t.Errorf("errorCategory(%v) = %v, want %v", err, category, panicError)
+	}
+}
+
+func TestCountErrorsByCategory(t *testing.T) {
+	entries := []ErrorLogEntry{
+		newErrorLogEntry("a.raw", 1, "x", errWrongLineFormat),
+		newErrorLogEntry("a.raw", 2, "y", errWrongLineFormat),
+		newErrorLogEntry("a.raw", 3, "z", errParserTime),
+	}
+	counts := countErrorsByCategory(entries)
+	want := map[string]int{"format": 2, "code": 0, "timestamp": 0, "date": 0, "panic": 1, "read": 0}
+	for name, count := range want {
+		if counts[name] != count {
+			t.Errorf("%s errors = %d, want %d", name, counts[name], count)
+		}
+	}
+}
+
+// The error log entry of the recovered panic tells the field and the recovered value
+func TestParseEventPanicMessage(t *testing.T) {
+	cfg := testConfig()
+	cfg.vodLog = true
+	eventLogChan := make(chan EventLogEntry)
+	close(eventLogChan)
+	line := rawLine(t, "0000000001", "47", time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC), "")
+	_, _, _, _, _, err := parseEvent(cfg, line, eventLogChan, LineSource{"MSO1", "panic.raw", 7}, time.Now())
+	if want := "Parser time exception: panic parsing the payload: send on closed channel"; err == nil || err.Error() != want {
+		t.Fatalf("parseEvent = %v, want %q", err, want)
+	}
+	dir := withOutputDir(t)
+	printErrorLogs([]ErrorLogEntry{newErrorLogEntry("panic.raw", 7, line, err)})
+	want := "File: panic.raw \t lineNo: 7\t Category: panic\t Error:" + err.Error() + "\nEntry:[" + line + "]\n"
+	if got := readOutput(t, dir, "errorlog.txt"); got != want {
+		t.Errorf("errorlog.txt:\n%s\nwant:\n%s", got, want)
+	}
+}
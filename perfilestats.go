@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const perFileStatsFileName = "perFileStats.csv"
+
+// Row of the -per-file-stats output, taken from the file result by the collector
+type FileStats struct {
+	fileName    string
+	mso         string
+	lines       int
+	validEvents int
+	errors      int
+	packages    int
+	firstEvent  time.Time
+	lastEvent   time.Time
+	bytes       int
+}
+
+func newFileStats(result FileResult) FileStats {
+	return FileStats{
+		fileName:    result.fileName,
+		mso:         msoName(result.fileName),
+		lines:       result.lines,
+		validEvents: result.msoStats.events,
+		errors:      len(result.errors),
+		packages:    len(result.packages),
+		firstEvent:  result.firstEvent,
+		lastEvent:   result.lastEvent,
+		bytes:       result.bytes,
+	}
+}
+
+// Valid event counted into the file bytes and time range
+func (result *FileResult) addEventStats(timestamp time.Time, eventSize int) {
+	result.bytes += eventSize
+	if result.firstEvent.IsZero() || timestamp.Before(result.firstEvent) {
+		result.firstEvent = timestamp
+	}
+	if timestamp.After(result.lastEvent) {
+		result.lastEvent = timestamp
+	}
+}
+
+// Merges the range and bytes of a -split-workers partial result
+func (result *FileResult) mergeEventStats(partial FileResult) {
+	if partial.firstEvent.IsZero() {
+		return
+	}
+	result.addEventStats(partial.firstEvent, partial.bytes)
+	result.addEventStats(partial.lastEvent, 0)
+}
+
+// Sorted by the file path, the files without valid events get empty timestamps
+func printFileStats(stats []FileStats) {
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].fileName < stats[j].fileName
+	})
+
+	w, err := createOutputFile(perFileStatsFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "file, mso, lines, validEvents, errors, packages, firstEvent, lastEvent, bytes")
+	for _, file := range stats {
+		first, last := "", ""
+		if !file.firstEvent.IsZero() {
+			first, last = file.firstEvent.String(), file.lastEvent.String()
+		}
+		fmt.Fprintf(w, "%s, %s, %d, %d, %d, %d, %s, %s, %d\n",
+			file.fileName, file.mso, file.lines, file.validEvents, file.errors, file.packages, first, last, file.bytes)
+	}
+	w.Close()
+}
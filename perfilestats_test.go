@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// A row per file sorted by the path, the error line counted in its file only
+func TestPerFileStats(t *testing.T) {
+	dir := withOutputDir(t)
+	input := t.TempDir()
+	// The raw timestamps are read in the local time
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.Local)
+	second := writeInput(t, input, "b_MSO2.raw",
+		rawLine(t, "dev2", "50", start.Add(time.Minute), ""),
+		"garbage",
+		rawLine(t, "dev2", "50", start, "0A0B"))
+	first := writeInput(t, input, "a_MSO1.raw", rawLine(t, "dev1", "50", start, ""))
+
+	cfg := testConfig()
+	cfg.perFileStats = true
+	_, result := processPackages(t, cfg, []string{second, first})
+	if len(result.fileStats) != 2 {
+		t.Fatalf("%d file stats, want 2", len(result.fileStats))
+	}
+	printFileStats(result.fileStats)
+
+	want := "file, mso, lines, validEvents, errors, packages, firstEvent, lastEvent, bytes\n" +
+		fmt.Sprintf("%s, MSO1, 1, 1, 0, 0, %v, %v, 5\n", first, start, start) +
+		fmt.Sprintf("%s, MSO2, 3, 2, 1, 0, %v, %v, 12\n", second, start, start.Add(time.Minute))
+	if got := readOutput(t, dir, perFileStatsFileName); got != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", perFileStatsFileName, got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Copies of the fixture, a directory of files of the same MSO
+func fixtureFiles(b *testing.B, copies int) []string {
+	b.Helper()
+	data, err := os.ReadFile(fixtureFileName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	dir := b.TempDir()
+	files := make([]string, 0, copies)
+	for i := 0; i < copies; i++ {
+		fileName := filepath.Join(dir, fmt.Sprintf("p%03d_MSO1.raw", i))
+		if err := os.WriteFile(fileName, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, fileName)
+	}
+	return files
+}
+
+// End to end throughput of the fixture files, read, parsed and simulated,
+// by the files at a time and the -split workers per file on the same input
+func BenchmarkProcess(b *testing.B) {
+	files := fixtureFiles(b, 20)
+	info, err := os.Stat(files[0])
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, concurrency := range []int{1, 4} {
+		for _, splitWorkers := range []int{0, 2, 4} {
+			b.Run(fmt.Sprintf("c%d/split%d", concurrency, splitWorkers), func(b *testing.B) {
+				cfg := testConfig()
+				cfg.concurrency = concurrency
+				cfg.splitWorkers = splitWorkers
+				b.SetBytes(info.Size() * int64(len(files)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := Process(context.Background(), cfg, files, nil, newBufferState(), time.Now()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Sort of the packages of the fixture, by time and device
+func BenchmarkSortPackages(b *testing.B) {
+	files := fixtureFiles(b, 20)
+	cfg := testConfig()
+	cfg.concurrency = 4
+	result, err := Process(context.Background(), cfg, files, nil, newBufferState(), time.Now())
+	if err != nil || len(result.packages) == 0 {
+		b.Fatalf("Process: %d packages, %v", len(result.packages), err)
+	}
+	packages := make(PackageList, len(result.packages))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(packages, result.packages)
+		sort.Stable(packages)
+	}
+}
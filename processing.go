@@ -0,0 +1,696 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Per device buffer fill, shared by all the file workers
+type BufferState struct {
+	sync.Mutex
+	sizes      map[string]int
+	bytes      map[string]int
+	stats      map[string]*DeviceBufferStats
+	lastEvents map[string]deviceEvent
+	activity   map[string]*DeviceActivity
+}
+
+// Last buffered event of a device, for the timer and final flushes
+type deviceEvent struct {
+	timestamp time.Time
+	eventCode string
+	mso       string
+}
+
+func newBufferState() *BufferState {
+	return &BufferState{
+		sizes:      make(map[string]int),
+		bytes:      make(map[string]int),
+		stats:      make(map[string]*DeviceBufferStats),
+		lastEvents: make(map[string]deviceEvent),
+		activity:   make(map[string]*DeviceActivity),
+	}
+}
+
+func (state *BufferState) devices() int {
+	state.Lock()
+	defer state.Unlock()
+	return len(state.sizes)
+}
+
+// Bytes ingested per device, and overall
+func (state *BufferState) deviceBytes() (bytes map[string]int, total int) {
+	state.Lock()
+	defer state.Unlock()
+	bytes = make(map[string]int, len(state.bytes))
+	for deviceId, deviceBytes := range state.bytes {
+		bytes[deviceId] = deviceBytes
+		total += deviceBytes
+	}
+	return
+}
+
+const (
+	initBufferRandom = "random"
+	initBufferZero   = "zero"
+	initBufferFixed  = "fixed"
+)
+
+func parseInitBuffer(value string) (mode string, fill int, err error) {
+	switch value {
+	case initBufferRandom, initBufferZero:
+		return value, 0, nil
+	}
+	fill, err = strconv.Atoi(value)
+	if err != nil || fill < 0 || fill > BuffWaterMarkSize {
+		return "", 0, fmt.Errorf("Wrong initial buffer fill: %s, expected random, zero or 0..%d", value, BuffWaterMarkSize)
+	}
+	return initBufferFixed, fill, nil
+}
+
+// Buffer fill of a device seen for the first time. Only the random mode
+// draws from the -seed source, so -seed makes a difference for it alone. The devices
+// draw in the files order whatever the -c, with -split in the order the
+// split workers reach them, which -seed does not pin down.
+func (cfg *Config) initialBufferFill() int {
+	switch cfg.initBuffer {
+	case initBufferZero:
+		return 0
+	case initBufferFixed:
+		return cfg.initBufferFill
+	}
+	cfg.randomLock.Lock()
+	defer cfg.randomLock.Unlock()
+	return cfg.random.Intn(BuffWaterMarkSize)
+}
+
+func (state *BufferState) isSeeded(deviceId string) bool {
+	_, ok := state.sizes[deviceId]
+	return ok
+}
+
+// Initial fill of a device seen for the first time
+func (state *BufferState) seed(deviceId string, fill int) {
+	state.sizes[deviceId] = fill
+}
+
+// -watermark comparison modes
+const (
+	// Send when the event would take the buffer over the watermark, an event
+	// filling it up exactly still fits. The model of the iGuide R31 the analizer
+	// has always used, the default.
+	watermarkOver = ">"
+	// Send when the event would fill the buffer up to the watermark or over it,
+	// for the firmware sending as soon as the buffer is full
+	watermarkReached = ">="
+)
+
+// The watermark packing of the analizer: the event goes into the device buffer,
+// unless it would take the buffer over BuffWaterMarkSize (or up to it, in the
+// watermarkReached mode). Then the buffered events are sent as a package and the
+// buffer starts over with this event alone.
+// The device is seeded and state is locked by the caller.
+func simulateBuffer(deviceId string, eventSize int, state *BufferState, mode string) (send bool) {
+	fill := state.sizes[deviceId] + eventSize
+	if fill > BuffWaterMarkSize || (mode == watermarkReached && fill == BuffWaterMarkSize) {
+		state.sizes[deviceId] = eventSize
+		return true
+	}
+	state.sizes[deviceId] += eventSize
+	return false
+}
+
+// Outcome of a single input file, merged by the collector
+type FileResult struct {
+	fileName string
+	opened   bool
+	lines    int
+	packages []Package
+	errors   []ErrorLogEntry
+	msoStats *MsoStats
+	trace    []BufferTraceEntry
+	// Cancelled before the end of the file
+	interrupted bool
+	// Header of the -in-format csv input, nil for the raw clickstrings
+	columns *CsvColumns
+	// Lines left out by -sample
+	sampledOut int
+	// Codes not in the commands list, kept or dropped
+	unknownCodes map[string]*UnknownCode
+	// Valid events below -min-size, left out of the buffers
+	smallEvents int
+	// Blank lines skipped, and no events lines at all
+	blankLines int
+	empty      bool
+	// Valid event bytes and time range, for -per-file-stats
+	bytes      int
+	firstEvent time.Time
+	lastEvent  time.Time
+	// With several files at a time the valid events wait for the buffer step,
+	// taken in the files order
+	deferred bool
+	events   []bufferEvent
+	// Run of the file, shared with the other files of the Process call
+	run *processRun
+	// Size and time of the file as read, for -state, nil after a read error
+	state *FileState
+}
+
+// Valid event on its way to the device buffer
+type bufferEvent struct {
+	timestamp time.Time
+	deviceId  string
+	eventCode string
+	eventSize int
+}
+
+// Deferred leaves the buffer step of the events to simulateEvents.
+func processFile(ctx context.Context, cfg *Config, run *processRun, fileName string, eventLogChan chan<- EventLogEntry, buffers *BufferState, now time.Time, deferred bool) FileResult {
+	result := FileResult{fileName: fileName, deferred: deferred, run: run}
+
+	logDebug("Processing: %s", fileName)
+	file, err := openInput(fileName)
+	if err != nil {
+		logWarn("Error opening file: %v", err)
+		result.errors = append(result.errors, newErrorLogEntry(fileName, 0, "", err))
+		return result
+	}
+	defer file.Close()
+	result.opened = true
+	// Modification time at the open, a change while reading shows in the next run
+	info, statErr := statInput(fileName)
+	input := &countingReader{reader: file}
+
+	mso := msoName(fileName)
+	result.msoStats = newMsoStats()
+	scanner := newLineScanner(input)
+	headerLines := 0
+	if cfg.inputFormat == csvInput {
+		if !scanner.Scan() {
+			// Empty file, nothing to validate
+			result.empty = scanner.Err() == nil
+			if result.empty && statErr == nil {
+				result.state = &FileState{input.bytes, info.ModTime().UTC()}
+			}
+			return result
+		}
+		headerLines = 1
+		if result.columns, err = parseCsvHeader(scanner.Text()); err != nil {
+			logWarn("Skipping %s: %v", fileName, err)
+			result.errors = append(result.errors, newErrorLogEntry(fileName, 1, scanner.Text(), err))
+			return result
+		}
+	}
+	reader := newLineReader(scanner, headerLines, cfg.headLines, cfg.tailLines)
+	sampler := cfg.newSampler(fileName)
+	if cfg.splitWorkers > 1 {
+		processLinesByDevice(ctx, cfg, reader, sampler, &result, mso, eventLogChan, buffers, now)
+	} else {
+		for job, ok := reader.next(); ok; job, ok = reader.next() {
+			if cfg.isSampledOut(sampler) {
+				result.sampledOut++
+				continue
+			}
+			result.processLine(cfg, job.line, job.lineNo, mso, eventLogChan, buffers, now)
+			if isCancelled(ctx, job.lineNo) {
+				result.interrupted = true
+				break
+			}
+		}
+	}
+	result.lines = reader.count
+	result.blankLines = reader.blank
+	result.empty = reader.count == 0 && reader.Err() == nil && !result.interrupted
+	if result.empty {
+		logDebug("No events in %s", fileName)
+	}
+	if err := reader.Err(); err != nil {
+		// Read error or too long line, the rest of the file is lost
+		logWarn("Error reading file %s after line %d: %v", fileName, reader.lineNo, err)
+		result.errors = append(result.errors, newErrorLogEntry(fileName, reader.lineNo+1, "", err))
+	} else if reader.headLines > 0 && reader.count >= reader.headLines {
+		// Cut short by -head, not recorded: the next run takes the file again
+		logDebug("Not in the state, stopped by -head: %s", fileName)
+	} else if statErr == nil {
+		result.state = &FileState{input.bytes, info.ModTime().UTC()}
+	}
+	return result
+}
+
+func (result *FileResult) addUnknownCode(code, line string) {
+	if result.unknownCodes == nil {
+		result.unknownCodes = make(map[string]*UnknownCode)
+	}
+	addUnknownCode(result.unknownCodes, code, 1, line)
+}
+
+func (result *FileResult) processLine(cfg *Config, line string, lineNo int, mso string, eventLogChan chan<- EventLogEntry, buffers *BufferState, now time.Time) {
+	// The arguments would be boxed on every line even with the debug level off
+	debug := debugEnabled()
+	if debug {
+		logDebug("Got next line: %s", line)
+	}
+	atomic.AddUint64(&result.run.progress.lines, 1)
+	start := startTiming()
+	timestamp, received, deviceId, eventSize, eventCode, err := parseInputEvent(cfg, line, result.columns, eventLogChan, LineSource{mso, result.fileName, lineNo}, now)
+	addTiming(&timings.parse, start)
+
+	if debug {
+		logDebug("Parsed into: %v %s %d %s %v", timestamp, deviceId, eventSize, eventCode, err)
+	}
+
+	if errors.Is(err, errUnknownCode) {
+		result.addUnknownCode(unknownCodeOfLine(line, "", cfg.codeOffset), cfg.anonymizeLine(line, result.columns))
+	} else if err == nil && strings.HasPrefix(eventCode, unknownCodePrefix) {
+		result.addUnknownCode(unknownCodeOfLine(line, eventCode, cfg.codeOffset), cfg.anonymizeLine(line, result.columns))
+	}
+
+	if err != nil {
+		result.errors = append(result.errors, newErrorLogEntry(result.fileName, lineNo, cfg.anonymizeLine(line, result.columns), err))
+		if parseErrors := atomic.AddUint64(&result.run.progress.errors, 1); cfg.maxErrors > 0 && parseErrors == cfg.maxErrors {
+			result.run.cancel(errMaxErrors)
+		}
+		return
+	}
+
+	if eventSize < cfg.minEventSize {
+		result.smallEvents++
+		if debug {
+			logDebug("Below min size: %v %s %d %s", timestamp, deviceId, eventSize, eventCode)
+		}
+		return
+	}
+
+	atomic.AddUint64(&result.run.progress.events, 1)
+	result.msoStats.addEvent(deviceId, eventCode)
+	result.addEventStats(timestamp, eventSize)
+	if keyNames != nil && result.columns == nil {
+		if clickString := lineClickString(line, cfg.codeOffset); strings.EqualFold(clickString[0:2], keyPressCode) {
+			shift := timestampWidth(clickString, cfg.timestampBytes) - defaultTimestampBytes
+			if key, ok := decodeKeyPress(clickString, shift); ok {
+				result.msoStats.keys[key]++
+			}
+		}
+	}
+	if dbSink != nil {
+		dbSink.addEvent(timestamp, received, deviceId, eventCode, mso, eventSize)
+	}
+	if kafkaSink != nil {
+		kafkaSink.addEvent(timestamp, received, deviceId, eventCode, mso, eventSize)
+	}
+
+	event := bufferEvent{timestamp, deviceId, eventCode, eventSize}
+	if result.deferred {
+		// The Id is a slice of the line, not to keep all the lines around
+		event.deviceId = strings.Clone(deviceId)
+		result.events = append(result.events, event)
+		return
+	}
+	result.simulate(cfg, event, mso, buffers)
+}
+
+// Buffer step of the deferred events, in the file order
+func (result *FileResult) simulateEvents(cfg *Config, buffers *BufferState) {
+	mso := msoName(result.fileName)
+	for _, event := range result.events {
+		result.simulate(cfg, event, mso, buffers)
+	}
+	result.events = nil
+}
+
+func (result *FileResult) simulate(cfg *Config, event bufferEvent, mso string, buffers *BufferState) {
+	timestamp, deviceId, eventCode, eventSize := event.timestamp, event.deviceId, event.eventCode, event.eventSize
+	debug := debugEnabled()
+	buffers.Lock()
+	defer buffers.Unlock()
+	if !buffers.isSeeded(deviceId) {
+		// First occurence
+		buffers.seed(deviceId, cfg.initialBufferFill())
+	}
+	buffers.bytes[deviceId] += eventSize
+	if cfg.deviceActivity {
+		buffers.addActivity(deviceId, timestamp)
+	}
+	if debug {
+		logDebug("Buff: %d", buffers.sizes[deviceId])
+		logDebug("Watermark: %d", BuffWaterMarkSize)
+	}
+
+	if cfg.isSuppressed(eventCode, timestamp) || (cfg.diagnosticsOnly && !isDiagnosticEvent(eventCode)) {
+		// If supress diagnostic commands is requested, then ignore them within the window,
+		// and the other commands for the diagnostics only report
+		if debug {
+			logDebug("Skipped: %v %s %d %s", timestamp, deviceId, eventSize, eventCode)
+		}
+	} else {
+		if cfg.flushInterval > 0 {
+			last, ok := buffers.lastEvents[deviceId]
+			if ok && buffers.sizes[deviceId] > 0 && timestamp.Sub(last.timestamp) > cfg.flushInterval {
+				// The timer went off before this event, send whatever was buffered
+				pkg := Pack(last.timestamp.Add(cfg.flushInterval), deviceId, last.eventCode, mso)
+				result.addPackage(pkg)
+				if debug {
+					logDebug("Flushed package: %v", pkg)
+				}
+				buffers.sizes[deviceId] = 0
+			}
+		}
+		if cfg.flushInterval > 0 || cfg.flushFinal {
+			buffers.lastEvents[deviceId] = deviceEvent{timestamp, eventCode, mso}
+		}
+
+		fillBefore := buffers.sizes[deviceId]
+		crossed := simulateBuffer(deviceId, eventSize, buffers, cfg.watermarkMode)
+		if crossed {
+			pkg := Pack(timestamp, deviceId, eventCode, mso)
+			// Send a new package
+			result.addPackage(pkg)
+			if debug {
+				logDebug("Sent package: %v", pkg)
+			}
+		}
+		if cfg.bufferStats {
+			buffers.deviceStats(deviceId).add(buffers.sizes[deviceId], fillBefore, crossed)
+		}
+		if cfg.isTracedDevice(deviceId) {
+			result.trace = append(result.trace,
+				BufferTraceEntry{timestamp, deviceId, eventCode, eventSize, buffers.sizes[deviceId], crossed})
+		}
+	}
+}
+
+// End of input flush, one package per device with a non-empty buffer,
+// sent at the device last event time
+func (state *BufferState) flushAll() []Package {
+	state.Lock()
+	defer state.Unlock()
+
+	packages := []Package{}
+	for deviceId, last := range state.lastEvents {
+		if state.sizes[deviceId] == 0 {
+			continue
+		}
+		pkg := Pack(last.timestamp, deviceId, last.eventCode, last.mso)
+		logDebug("Final flush of %d bytes: %v", state.sizes[deviceId], pkg)
+		packages = append(packages, pkg)
+		state.sizes[deviceId] = 0
+	}
+	return packages
+}
+
+func (result *FileResult) addPackage(pkg Package) {
+	atomic.AddUint64(&result.run.progress.packages, 1)
+	result.packages = append(result.packages, pkg)
+	result.msoStats.addPackage(pkg)
+}
+
+type lineJob struct {
+	lineNo int
+	line   string
+}
+
+// Device Id is the token before the clickstring, the last one in the line
+func lineDeviceId(line string) string {
+	end := strings.LastIndex(line, " ")
+	if end < 0 {
+		return ""
+	}
+	return line[strings.LastIndex(line[:end], " ")+1 : end]
+}
+
+// Splits the lines of a single file across splitWorkers goroutines keyed by device Id.
+// All the events of a device go to the same worker in the file order, so the device
+// buffer accumulates exactly as in the sequential mode. The order between devices is
+// not preserved, the packages are sorted by timestamp and the errors by line afterwards.
+func processLinesByDevice(ctx context.Context, cfg *Config, reader *LineReader, sampler *rand.Rand, result *FileResult, mso string, eventLogChan chan<- EventLogEntry, buffers *BufferState, now time.Time) {
+	jobs := make([]chan lineJob, cfg.splitWorkers)
+	partials := make([]FileResult, cfg.splitWorkers)
+
+	var wg sync.WaitGroup
+	wg.Add(cfg.splitWorkers)
+	for i := range jobs {
+		jobs[i] = make(chan lineJob, 1024)
+		partials[i] = FileResult{fileName: result.fileName, msoStats: newMsoStats(), columns: result.columns, deferred: result.deferred, run: result.run}
+		go func(partial *FileResult, jobs <-chan lineJob) {
+			defer wg.Done()
+			for job := range jobs {
+				partial.processLine(cfg, job.line, job.lineNo, mso, eventLogChan, buffers, now)
+			}
+		}(&partials[i], jobs[i])
+	}
+
+	for job, ok := reader.next(); ok; job, ok = reader.next() {
+		if cfg.isSampledOut(sampler) {
+			result.sampledOut++
+			continue
+		}
+		deviceId := lineDeviceId(job.line)
+		if result.columns != nil {
+			deviceId = result.columns.deviceIdOf(job.line)
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(deviceId))
+		jobs[hash.Sum32()%uint32(cfg.splitWorkers)] <- job
+		if isCancelled(ctx, job.lineNo) {
+			result.interrupted = true
+			break
+		}
+	}
+	for _, workerJobs := range jobs {
+		close(workerJobs)
+	}
+	wg.Wait()
+
+	for _, partial := range partials {
+		result.packages = append(result.packages, partial.packages...)
+		result.errors = append(result.errors, partial.errors...)
+		result.msoStats.merge(partial.msoStats)
+		result.trace = append(result.trace, partial.trace...)
+		result.smallEvents += partial.smallEvents
+		result.mergeEventStats(partial)
+		// The events of a device are all in one partial, in the file order
+		result.events = append(result.events, partial.events...)
+		for code, unknown := range partial.unknownCodes {
+			if result.unknownCodes == nil {
+				result.unknownCodes = make(map[string]*UnknownCode)
+			}
+			addUnknownCode(result.unknownCodes, code, unknown.events, unknown.samples...)
+		}
+	}
+}
+
+// Runs up to concurrency workers over the files, results come back in completion order.
+// The files are parsed in parallel, while the buffer steps are taken a file at a time in
+// the files order: a device found in several files fills its buffer as with -c 1.
+// The pool stats, when not nil, get the per worker counters.
+func processFiles(ctx context.Context, cfg *Config, run *processRun, files []string, eventLogChan chan<- EventLogEntry, buffers *BufferState, now time.Time, pool *PoolStats) <-chan FileResult {
+	workers := cfg.concurrency
+	if workers > len(files) {
+		workers = len(files)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	fileChan := make(chan fileJob)
+	results := make(chan FileResult)
+	// Closed once the file buffer step is done, the next file waits for it
+	turns := make([]chan struct{}, len(files))
+	for i := range turns {
+		turns[i] = make(chan struct{})
+	}
+	deferred := workers > 1
+
+	if pool != nil {
+		pool.workers = make([]WorkerStats, workers)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			defer wg.Done()
+			idleSince := time.Now()
+			for job := range fileChan {
+				start := time.Now()
+				result := processFile(ctx, cfg, run, job.fileName, eventLogChan, buffers, now, deferred)
+				var waited time.Duration
+				if deferred {
+					waitStart := time.Now()
+					if job.index > 0 {
+						<-turns[job.index-1]
+					}
+					waited = time.Since(waitStart)
+					result.simulateEvents(cfg, buffers)
+					close(turns[job.index])
+				}
+				if pool != nil {
+					stats := &pool.workers[worker]
+					stats.files++
+					stats.idle += start.Sub(idleSince) + waited
+					stats.busy += time.Since(start) - waited
+					pool.resultDone()
+				}
+				atomic.AddUint64(&run.progress.files, 1)
+				results <- result
+				idleSince = time.Now()
+			}
+			if pool != nil {
+				pool.workers[worker].idle += time.Since(idleSince)
+			}
+		}(i)
+	}
+
+	go func() {
+		defer close(fileChan)
+		for i, fileName := range files {
+			// No new files once cancelled, the ones out wait only for the earlier files
+			select {
+			case fileChan <- fileJob{i, fileName}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+type fileJob struct {
+	index    int
+	fileName string
+}
+
+// -sample source of a file, seeded by the -seed and the file name: the lines kept
+// are the same whatever the -c workers, nil when all the lines are kept
+func (cfg *Config) newSampler(fileName string) *rand.Rand {
+	if cfg.sampleRate >= 1 {
+		return nil
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(inputBaseName(fileName)))
+	return rand.New(rand.NewSource(cfg.seed ^ int64(hash.Sum64())))
+}
+
+// -sample keeps a random sampleRate fraction of the lines, drawn from the file sampler
+func (cfg *Config) isSampledOut(sampler *rand.Rand) bool {
+	return sampler != nil && sampler.Float64() >= cfg.sampleRate
+}
+
+// Within a file the context is checked every cancelCheckLines lines
+const cancelCheckLines = 10000
+
+func isCancelled(ctx context.Context, lineNo int) bool {
+	return lineNo%cancelCheckLines == 0 && ctx.Err() != nil
+}
+
+// State of a single Process call, shared by its file workers
+type processRun struct {
+	// Stops the run, e.g. when -max-errors is reached
+	cancel   context.CancelCauseFunc
+	progress Progress
+}
+
+var errMaxErrors = errors.New("Too many parse errors")
+
+// Aggregated outcome of a Process run
+type ProcessResult struct {
+	files        int
+	totalEvents  int
+	validEvents  int
+	skippedFiles int
+	sampledOut   int
+	smallEvents  int
+	emptyFiles   int
+	blankLines   int
+	packages     []Package
+	// With -workers-metrics
+	pool *PoolStats
+	// With -per-file-stats
+	fileStats []FileStats
+	// Read and parse errors, in the completion order
+	errors []ErrorLogEntry
+	// With -buffer-trace
+	trace        []BufferTraceEntry
+	msoStats     map[string]*MsoStats
+	unknownCodes map[string]*UnknownCode
+	// Files read to the end, for -state
+	processed map[string]FileState
+}
+
+// Processes the files on the worker pool and collects the results. On ctx cancellation
+// no new files are started and the files in progress stop early; the partial results
+// collected so far are returned together with ctx.Err(). The interrupted files are not
+// in the processed ones, so the next incremental run takes them again.
+// The run keeps no package level state, several runs can go at the same time.
+func Process(ctx context.Context, cfg *Config, files []string, eventLogChan chan<- EventLogEntry, buffers *BufferState, now time.Time) (ProcessResult, error) {
+	result := ProcessResult{
+		packages:     []Package{},
+		msoStats:     make(map[string]*MsoStats),
+		unknownCodes: make(map[string]*UnknownCode),
+		processed:    make(map[string]FileState),
+	}
+	run := &processRun{}
+	ctx, run.cancel = context.WithCancelCause(ctx)
+	defer run.cancel(nil)
+	if cfg.heartbeat > 0 {
+		defer startHeartbeat(&run.progress, cfg.heartbeat, len(files), now)()
+	}
+
+	if cfg.workerMetrics {
+		result.pool = &PoolStats{}
+	}
+
+	// Single collector, the workers never touch the aggregated results
+	for fileResult := range processFiles(ctx, cfg, run, files, eventLogChan, buffers, now, result.pool) {
+		result.pool.resultCollected()
+		result.errors = append(result.errors, fileResult.errors...)
+		if !fileResult.opened {
+			result.skippedFiles++
+			continue
+		}
+		result.files++
+		result.totalEvents += fileResult.lines - fileResult.sampledOut
+		result.sampledOut += fileResult.sampledOut
+		result.smallEvents += fileResult.smallEvents
+		result.blankLines += fileResult.blankLines
+		if fileResult.empty {
+			result.emptyFiles++
+		}
+		result.validEvents += fileResult.msoStats.events
+		result.packages = append(result.packages, fileResult.packages...)
+		result.trace = append(result.trace, fileResult.trace...)
+		msoStatsOf(result.msoStats, msoName(fileResult.fileName)).merge(fileResult.msoStats)
+		mergeUnknownCodes(result.unknownCodes, fileResult.unknownCodes)
+		if cfg.perFileStats {
+			result.fileStats = append(result.fileStats, newFileStats(fileResult))
+		}
+		if !fileResult.interrupted && fileResult.state != nil {
+			result.processed[fileResult.fileName] = *fileResult.state
+		}
+	}
+	return result, context.Cause(ctx)
+}
+
+// Error log in the input order, regardless of the workers completion order
+func sortErrorsLog() {
+	sort.SliceStable(errorsLog, func(i, j int) bool {
+		if errorsLog[i].fileName != errorsLog[j].fileName {
+			return errorsLog[i].fileName < errorsLog[j].fileName
+		}
+		return errorsLog[i].lineNo < errorsLog[j].lineNo
+	})
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The devices span all the files, so the buffer of a device depends on the order its files are taken in
+func writeSpreadFiles(t *testing.T, dir string, files, devices, eventsPerFile int) []string {
+	r := rand.New(rand.NewSource(1))
+	start := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	codes := []string{"43", "47", "4B", "50"}
+	fileNames := make([]string, 0, files)
+	for i := 0; i < files; i++ {
+		lines := make([]string, 0, eventsPerFile)
+		for j := 0; j < eventsPerFile; j++ {
+			timestamp := start.Add(time.Duration(i*eventsPerFile+j) * time.Second)
+			payload := make([]byte, r.Intn(120))
+			r.Read(payload)
+			lines = append(lines, rawLine(t, fmt.Sprintf("dev%d", r.Intn(devices)), codes[r.Intn(len(codes))], timestamp, fmt.Sprintf("%X", payload)))
+		}
+		fileNames = append(fileNames, writeInput(t, dir, fmt.Sprintf("p%02d_MSO1.raw", i), lines...))
+	}
+	return fileNames
+}
+
+func processPackages(t *testing.T, cfg *Config, files []string) (PackageList, ProcessResult) {
+	buffers := newBufferState()
+	result, err := Process(context.Background(), cfg, files, nil, buffers, time.Now())
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	packages := PackageList(result.packages)
+	sort.Stable(packages)
+	return packages, result
+}
+
+func TestProcessConcurrencyDeterministic(t *testing.T) {
+	files := writeSpreadFiles(t, t.TempDir(), 10, 20, 300)
+	for _, flushInterval := range []time.Duration{0, time.Minute} {
+		t.Run(fmt.Sprintf("flush %v", flushInterval), func(t *testing.T) {
+			cfg := testConfig()
+			cfg.flushInterval = flushInterval
+			cfg.concurrency = 1
+			sequential, sequentialResult := processPackages(t, cfg, files)
+			if len(sequential) == 0 {
+				t.Fatal("no packages with -c 1")
+			}
+
+			for _, concurrency := range []int{2, 4, 100} {
+				cfg.concurrency = concurrency
+				for run := 0; run < 3; run++ {
+					packages, result := processPackages(t, cfg, files)
+					if !reflect.DeepEqual(packages, sequential) {
+						t.Fatalf("-c %d run %d: %d packages, -c 1 has %d", concurrency, run, len(packages), len(sequential))
+					}
+					if result.totalEvents != sequentialResult.totalEvents || result.validEvents != sequentialResult.validEvents {
+						t.Fatalf("-c %d run %d: %d/%d events, -c 1 has %d/%d", concurrency, run,
+							result.validEvents, result.totalEvents, sequentialResult.validEvents, sequentialResult.totalEvents)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessConcurrencySplit(t *testing.T) {
+	files := writeSpreadFiles(t, t.TempDir(), 6, 20, 300)
+	cfg := testConfig()
+	cfg.concurrency = 1
+	sequential, _ := processPackages(t, cfg, files)
+
+	cfg.concurrency = 3
+	cfg.splitWorkers = 4
+	packages, _ := processPackages(t, cfg, files)
+	if !reflect.DeepEqual(packages, sequential) {
+		t.Fatalf("-c 3 -split 4: %d packages, -c 1 has %d", len(packages), len(sequential))
+	}
+}
+
+func TestSimulateBuffer(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		fill  int
+		sizes []int
+		sends []bool
+		want  int
+	}{
+		{"accumulation", watermarkOver, 0, []int{100, 200, 300}, []bool{false, false, false}, 600},
+		{"exact fit", watermarkOver, 650, []int{100}, []bool{false}, BuffWaterMarkSize},
+		{"exact fit, reached", watermarkReached, 650, []int{100}, []bool{true}, 100},
+		{"crossing", watermarkOver, 700, []int{100}, []bool{true}, 100},
+		{"reset after send", watermarkOver, 700, []int{100, 600, 100}, []bool{true, false, true}, 100},
+		// The event alone is over the watermark: sent with the next one
+		{"oversize event", watermarkOver, 0, []int{1000, 10}, []bool{true, true}, 10},
+	}
+	for _, test := range tests {
+		state := newBufferState()
+		state.seed("dev1", test.fill)
+		for i, size := range test.sizes {
+			if send := simulateBuffer("dev1", size, state, test.mode); send != test.sends[i] {
+				t.Errorf("%s: event %d of %d bytes, send = %v, want %v", test.name, i, size, send, test.sends[i])
+			}
+		}
+		if state.sizes["dev1"] != test.want {
+			t.Errorf("%s: buffer %d, want %d", test.name, state.sizes["dev1"], test.want)
+		}
+	}
+}
+
+// The first event of a device is seeded with the initial fill, the rest of the buffer
+// goes out with -flush-final at the device last event
+func TestSimulateSeedAndFlush(t *testing.T) {
+	cfg := testConfig()
+	cfg.flushFinal = true
+	result := FileResult{fileName: "a_MSO1.raw", msoStats: newMsoStats(), run: &processRun{}}
+	buffers := newBufferState()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	result.simulate(cfg, bufferEvent{start, "dev1", "Pulse", 400}, "MSO1", buffers)
+	if !buffers.isSeeded("dev1") || buffers.sizes["dev1"] != 400 {
+		t.Fatalf("after the first event: seeded %v, buffer %d, want 400", buffers.isSeeded("dev1"), buffers.sizes["dev1"])
+	}
+	result.simulate(cfg, bufferEvent{start.Add(time.Second), "dev1", "Pulse", 400}, "MSO1", buffers)
+	result.simulate(cfg, bufferEvent{start.Add(2 * time.Second), "dev1", "Highlight", 100}, "MSO1", buffers)
+	if len(result.packages) != 1 || !result.packages[0].timestamp.Equal(start.Add(time.Second)) {
+		t.Fatalf("packages %v, want one at %v", result.packages, start.Add(time.Second))
+	}
+
+	flushed := buffers.flushAll()
+	want := []Package{{start.Add(2 * time.Second), "dev1", "Highlight", "MSO1"}}
+	if !reflect.DeepEqual(flushed, want) {
+		t.Errorf("flushAll = %v, want %v", flushed, want)
+	}
+	if buffers.sizes["dev1"] != 0 || len(buffers.flushAll()) != 0 {
+		t.Errorf("buffer %d after the flush, want empty", buffers.sizes["dev1"])
+	}
+}
+
+// With -flush-interval the buffer goes out when the timer would go off, before the next event
+func TestSimulateFlushInterval(t *testing.T) {
+	cfg := testConfig()
+	cfg.flushInterval = time.Minute
+	result := FileResult{fileName: "a_MSO1.raw", msoStats: newMsoStats(), run: &processRun{}}
+	buffers := newBufferState()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	result.simulate(cfg, bufferEvent{start, "dev1", "Pulse", 100}, "MSO1", buffers)
+	result.simulate(cfg, bufferEvent{start.Add(30 * time.Second), "dev1", "Pulse", 100}, "MSO1", buffers)
+	if len(result.packages) != 0 {
+		t.Fatalf("packages %v within the interval, want none", result.packages)
+	}
+	result.simulate(cfg, bufferEvent{start.Add(5 * time.Minute), "dev1", "Lock", 100}, "MSO1", buffers)
+	want := []Package{{start.Add(90 * time.Second), "dev1", "Pulse", "MSO1"}}
+	if !reflect.DeepEqual(result.packages, want) || buffers.sizes["dev1"] != 100 {
+		t.Errorf("packages %v, buffer %d, want %v and 100", result.packages, buffers.sizes["dev1"], want)
+	}
+}
+
+// A missing file is counted as skipped and logged as a read error, the others go on
+func TestProcessMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	good := writeInput(t, dir, "a_MSO1.raw", rawLine(t, "dev1", "50", time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC), ""))
+	missing := dir + "/missing_MSO1.raw"
+	_, result := processPackages(t, testConfig(), []string{good, missing})
+	if result.skippedFiles != 1 || result.files != 1 || result.validEvents != 1 {
+		t.Errorf("skipped %d, files %d, events %d, want 1, 1, 1", result.skippedFiles, result.files, result.validEvents)
+	}
+	if len(result.errors) != 1 || result.errors[0].fileName != missing || result.errors[0].category != readError {
+		t.Errorf("errors log %v, want the read error of %s", errorsLog, missing)
+	}
+}
+
+// Lines past the 64KB bufio.Scanner default are read, the ones past -maxline are a read error
+func TestProcessLongLines(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	long := rawLine(t, "dev1", "4D", start, strings.Repeat("AB", 40*1024))
+	fileName := writeInput(t, t.TempDir(), "a_MSO1.raw", long, rawLine(t, "dev2", "50", start, ""))
+	_, result := processPackages(t, testConfig(), []string{fileName})
+	if result.validEvents != 2 || len(result.errors) != 0 {
+		t.Fatalf("%d events, errors %v, want 2 events and no errors", result.validEvents, result.errors)
+	}
+
+	previous := maxLineSize
+	maxLineSize = 64 * 1024
+	defer func() { maxLineSize = previous }()
+	_, result = processPackages(t, testConfig(), []string{fileName})
+	if result.validEvents != 0 || len(result.errors) != 1 || !strings.Contains(result.errors[0].err.Error(), "too long") {
+		t.Errorf("-maxline 65536: %d events, errors %v, want the too long line error", result.validEvents, result.errors)
+	}
+}
+
+// A sparse device never reaches the watermark, -flush-interval sends its buffer anyway
+func TestProcessFlushIntervalSparse(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	var lines []string
+	for i := 0; i < 5; i++ {
+		lines = append(lines, rawLine(t, "sparse", "50", start.Add(time.Duration(i)*10*time.Minute), ""))
+	}
+	fileName := writeInput(t, t.TempDir(), "a_MSO1.raw", lines...)
+
+	cfg := testConfig()
+	packages, _ := processPackages(t, cfg, []string{fileName})
+	if len(packages) != 0 {
+		t.Fatalf("%d packages without -flush-interval, want none", len(packages))
+	}
+	cfg.flushInterval = 5 * time.Minute
+	packages, _ = processPackages(t, cfg, []string{fileName})
+	if len(packages) != 4 {
+		t.Fatalf("%d packages with -flush-interval 5m, want 4", len(packages))
+	}
+	for i, pkg := range packages {
+		if want := start.Add(time.Duration(i)*10*time.Minute + 5*time.Minute); !pkg.timestamp.Equal(want) {
+			t.Errorf("package %d at %v, want %v", i, pkg.timestamp, want)
+		}
+	}
+}
+
+// A cancelled run returns the cause and the partial results, the file in progress stops
+// at the next check and is not recorded in the state
+func TestProcessCancel(t *testing.T) {
+	lines := make([]string, 0, 3*cancelCheckLines)
+	for i := 0; i < 3*cancelCheckLines; i++ {
+		lines = append(lines, "not a clickstring")
+	}
+	dir := t.TempDir()
+	bad := writeInput(t, dir, "a_MSO1.raw", lines...)
+	files := append([]string{bad}, writeSpreadFiles(t, dir, 3, 5, 10)...)
+
+	cfg := testConfig()
+	cfg.concurrency = 1
+	cfg.maxErrors = 5
+	result, err := Process(context.Background(), cfg, files, nil, newBufferState(), time.Now())
+	if err != errMaxErrors {
+		t.Fatalf("Process = %v, want %v", err, errMaxErrors)
+	}
+	if result.totalEvents != cancelCheckLines || len(result.errors) != cancelCheckLines {
+		t.Errorf("%d lines, %d errors, want %d, stopped at the first check", result.totalEvents, len(result.errors), cancelCheckLines)
+	}
+	if len(result.processed) != 0 {
+		t.Errorf("state %v of an interrupted file", result.processed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg.maxErrors = 0
+	result, err = Process(ctx, cfg, files, nil, newBufferState(), time.Now())
+	if err != context.Canceled || result.files == len(files) {
+		t.Errorf("cancelled Process = %v, %d files, want %v and not all the files", err, result.files, context.Canceled)
+	}
+}
+
+// -max-errors stops the run of the file, not the other runs
+func TestProcessFileMaxErrors(t *testing.T) {
+	bad := writeInput(t, t.TempDir(), "a_MSO1.raw", "bad", "bad", "bad")
+	cfg := testConfig()
+	cfg.maxErrors = 2
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	other, cancelOther := context.WithCancelCause(context.Background())
+	defer cancelOther(nil)
+	processFile(ctx, cfg, &processRun{cancel: cancel}, bad, nil, newBufferState(), time.Now(), false)
+	if context.Cause(ctx) != errMaxErrors {
+		t.Errorf("run cause %v, want %v", context.Cause(ctx), errMaxErrors)
+	}
+	if other.Err() != nil {
+		t.Errorf("other run cancelled: %v", context.Cause(other))
+	}
+}
+
+// -sample keeps about the fraction of the lines, the same ones for the same -seed
+// whatever the workers
+func TestProcessSample(t *testing.T) {
+	files := writeSpreadFiles(t, t.TempDir(), 2, 20, 2500)
+	cfg := testConfig()
+	cfg.sampleRate = 0.1
+
+	var kept []int
+	for run, concurrency := range []int{1, 1, 4} {
+		cfg.concurrency = concurrency
+		cfg.splitWorkers = 1 + run
+		cfg.setSeed(1)
+		_, result := processPackages(t, cfg, files)
+		if result.sampledOut+result.totalEvents != 5000 {
+			t.Fatalf("%d sampled out and %d kept, want 5000 lines", result.sampledOut, result.totalEvents)
+		}
+		kept = append(kept, result.validEvents)
+	}
+	if kept[0] < 400 || kept[0] > 600 {
+		t.Errorf("%d of 5000 lines kept at -sample 0.1, want about 500", kept[0])
+	}
+	if kept[0] != kept[1] || kept[0] != kept[2] {
+		t.Errorf("%v lines kept with the same seed", kept)
+	}
+	cfg.setSeed(2)
+	if _, result := processPackages(t, cfg, files); result.validEvents == kept[0] {
+		t.Errorf("%d lines kept with the seeds 1 and 2", kept[0])
+	}
+}
+
+// The events below -min-size are counted apart and kept out of the valid events
+func TestProcessMinEventSize(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	large := strings.Repeat("AB", 60)
+	file := writeInput(t, t.TempDir(), "sizes_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		rawLine(t, "dev1", "50", start.Add(time.Second), large),
+		rawLine(t, "dev2", "50", start.Add(2*time.Second), ""),
+		rawLine(t, "dev2", "50", start.Add(3*time.Second), large),
+		rawLine(t, "dev2", "50", start.Add(4*time.Second), ""))
+
+	cfg := testConfig()
+	cfg.minEventSize = 20
+	_, result := processPackages(t, cfg, []string{file})
+	if result.smallEvents != 3 || result.validEvents != 2 {
+		t.Errorf("-min-size 20: %d small and %d valid events, want 3 and 2", result.smallEvents, result.validEvents)
+	}
+
+	cfg.minEventSize = 0
+	_, result = processPackages(t, cfg, []string{file})
+	if result.smallEvents != 0 || result.validEvents != 5 {
+		t.Errorf("no -min-size: %d small and %d valid events, want 0 and 5", result.smallEvents, result.validEvents)
+	}
+}
+
+// Two Configs in one process: the runs at the same time, each as it is run alone,
+// next to a run stopped by -max-errors, and the parsing at the same time
+func TestProcessTwoConfigs(t *testing.T) {
+	files := writeSpreadFiles(t, t.TempDir(), 4, 10, 200)
+	all := testConfig()
+	all.concurrency = 2
+	large := testConfig()
+	large.concurrency = 3
+	large.minEventSize = 40
+	large.watermarkMode = watermarkReached
+
+	allAlone, allResult := processPackages(t, all, files)
+	largeAlone, largeResult := processPackages(t, large, files)
+	if largeResult.smallEvents == 0 || allResult.smallEvents != 0 {
+		t.Fatalf("small events %d and %d, want some with -min-size only", allResult.smallEvents, largeResult.smallEvents)
+	}
+	if reflect.DeepEqual(allAlone, largeAlone) {
+		t.Fatal("the same packages with both configs")
+	}
+	stopped := testConfig()
+	stopped.maxErrors = 1
+	bad := writeInput(t, t.TempDir(), "bad_MSO1.raw", "not a clickstring")
+	runs := []struct {
+		cfg    *Config
+		files  []string
+		want   PackageList
+		result ProcessResult
+		err    error
+	}{
+		{cfg: all, files: files, want: allAlone},
+		{cfg: large, files: files, want: largeAlone},
+		{cfg: stopped, files: append([]string{bad}, files...)},
+	}
+	var wg sync.WaitGroup
+	for i := range runs {
+		wg.Add(1)
+		go func(run int) {
+			defer wg.Done()
+			runs[run].result, runs[run].err = Process(context.Background(), runs[run].cfg, runs[run].files, nil, newBufferState(), time.Now())
+		}(i)
+	}
+	wg.Wait()
+	for i, run := range runs[:2] {
+		packages := PackageList(run.result.packages)
+		sort.Stable(packages)
+		if run.err != nil || !reflect.DeepEqual(packages, run.want) || len(run.result.errors) != 0 {
+			t.Errorf("run %d: %v, %d packages and %d errors, %d packages alone", i, run.err, len(packages), len(run.result.errors), len(run.want))
+		}
+	}
+	if runs[1].result.smallEvents != largeResult.smallEvents {
+		t.Errorf("-min-size run: %d small events, %d alone", runs[1].result.smallEvents, largeResult.smallEvents)
+	}
+	if runs[2].err != errMaxErrors || len(runs[2].result.errors) != 1 {
+		t.Errorf("-max-errors run: %v, %d errors, want %v", runs[2].err, len(runs[2].result.errors), errMaxErrors)
+	}
+
+	decimal := testConfig()
+	decimal.timestampBase = 10
+	decimal.timestampBytes = 5
+	timestamp := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	for _, cfg := range []*Config{all, decimal} {
+		clickString, err := encodeEvent(cfg, "50", timestamp, "AB")
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := "dev1 " + clickString
+		wg.Add(1)
+		go func(cfg *Config, line string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				parsed, _, _, _, _, err := parseEvent(cfg, line, nil, LineSource{"MSO1", "two.raw", i}, time.Now())
+				if err != nil || !parsed.Equal(timestamp) {
+					t.Errorf("base %d: %v %v, want %v", cfg.timestampBase, parsed, err, timestamp)
+					return
+				}
+			}
+		}(cfg, line)
+	}
+	wg.Wait()
+}
+
+// The events log entries keep the input file and line, the columns only with -trace-source
+func TestProcessTraceSource(t *testing.T) {
+	saved := traceSource
+	defer func() { traceSource = saved }()
+
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	file := writeInput(t, t.TempDir(), "trace_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		"",
+		rawLine(t, "dev2", "50", start.Add(time.Second), ""))
+
+	cfg := testConfig()
+	cfg.eventSequenceLog = true
+	eventLogChan := make(chan EventLogEntry, 10)
+	if _, err := Process(context.Background(), cfg, []string{file}, eventLogChan, newBufferState(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	close(eventLogChan)
+	var entries OrderedVodLogList
+	for entry := range eventLogChan {
+		entries = append(entries, entry)
+	}
+	sort.Stable(entries)
+	if len(entries) != 2 || entries[0].lineNo != 1 || entries[1].lineNo != 3 || entries[1].sourceFile != file {
+		t.Fatalf("entries %v, want lines 1 and 3 of %s", entries, file)
+	}
+
+	traceSource = false
+	compact := entries[1].String()
+	traceSource = true
+	if got, want := entries[1].String(), compact+fmt.Sprintf(", %s, 3", file); got != want {
+		t.Errorf("-trace-source entry %q, want %q", got, want)
+	}
+}
+
+// Events of half the watermark: the second fills the buffer up exactly, sent with the third
+// under >; under >= with the second, and the third reaches it again in the new buffer
+func TestProcessWatermarkBoundary(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	// 1 byte code, 4 bytes timestamp, the payload up to half the watermark
+	payload := strings.Repeat("AB", BuffWaterMarkSize/2-5)
+	file := writeInput(t, t.TempDir(), "boundary_MSO1.raw",
+		rawLine(t, "dev1", "50", second(0), payload),
+		rawLine(t, "dev1", "50", second(1), payload),
+		rawLine(t, "dev1", "50", second(2), payload))
+
+	tests := []struct {
+		mode string
+		want []time.Time
+	}{
+		{watermarkOver, []time.Time{second(2)}},
+		{watermarkReached, []time.Time{second(1), second(2)}},
+	}
+	for _, test := range tests {
+		cfg := testConfig()
+		cfg.watermarkMode = test.mode
+		packages, _ := processPackages(t, cfg, []string{file})
+		if len(packages) != len(test.want) {
+			t.Errorf("-watermark %s: packages %v, want at %v", test.mode, packages, test.want)
+			continue
+		}
+		for i, pkg := range packages {
+			if !pkg.timestamp.Equal(test.want[i]) {
+				t.Errorf("-watermark %s: packages %v, want at %v", test.mode, packages, test.want)
+				break
+			}
+		}
+	}
+}
+
+// The empty file and the file of blank lines count as empty, the blank lines are no errors
+func TestProcessEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	empty := filepath.Join(dir, "empty_MSO1.raw")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{
+		empty,
+		writeInput(t, dir, "blank_MSO1.raw", "", "   ", "\t", "\r"),
+		writeInput(t, dir, "events_MSO1.raw", rawLine(t, "dev1", "50", start, ""), " ", rawLine(t, "dev1", "50", start.Add(time.Second), "")),
+	}
+
+	_, result := processPackages(t, testConfig(), files)
+	if result.emptyFiles != 2 || result.blankLines != 5 {
+		t.Errorf("%d empty files and %d blank lines, want 2 and 5", result.emptyFiles, result.blankLines)
+	}
+	if result.totalEvents != 2 || result.validEvents != 2 || len(result.errors) != 0 {
+		t.Errorf("%d events, %d valid, errors %v, want the 2 events", result.totalEvents, result.validEvents, result.errors)
+	}
+}
+
+// A Windows file with the byte order mark and CRLF endings parses as the LF one
+func TestProcessBomCrlf(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	lines := []string{
+		rawLine(t, "dev1", "50", start, "AB"),
+		rawLine(t, "dev2", "50", start.Add(time.Second), "CD"),
+	}
+	unix := writeInput(t, dir, "unix_MSO1.raw", lines...)
+	windows := filepath.Join(dir, "windows_MSO1.raw")
+	if err := os.WriteFile(windows, []byte(utf8Bom+strings.Join(lines, "\r\n")+"\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig()
+	want, _ := processPackages(t, cfg, []string{unix})
+	packages, result := processPackages(t, cfg, []string{windows})
+	if len(result.errors) != 0 || result.validEvents != 2 {
+		t.Fatalf("%d valid events, errors %v", result.validEvents, result.errors)
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("packages %v, want %v", packages, want)
+	}
+}
+
+// Devices of the buffered events at the final flush, with the code of the last event
+func flushedEvents(t *testing.T, cfg *Config, files []string) map[string]string {
+	t.Helper()
+	buffers := newBufferState()
+	if _, err := Process(context.Background(), cfg, files, nil, buffers, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	events := make(map[string]string)
+	for _, pkg := range buffers.flushAll() {
+		events[pkg.deviceId] = pkg.eventCode
+	}
+	return events
+}
+
+// Only the diagnostic events go into the buffers of the -diagnostics-only report,
+// with -S the ones outside its window
+func TestProcessDiagnosticsOnly(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	file := writeInput(t, t.TempDir(), "diagnostics_MSO1.raw",
+		rawLine(t, "pulses", "50", second(0), ""),
+		rawLine(t, "pulses", "4C", second(1), ""),
+		rawLine(t, "unit", "55", second(2), ""),
+		rawLine(t, "mixed", "58", second(3), ""),
+		rawLine(t, "mixed", "50", second(4), ""))
+
+	cfg := testConfig()
+	cfg.flushFinal = true
+	want := map[string]string{"pulses": "`L`Lock", "unit": "`U`Unit Ident.", "mixed": "`P`Pulse"}
+	if events := flushedEvents(t, cfg, []string{file}); !reflect.DeepEqual(events, want) {
+		t.Errorf("all the events: %v, want %v", events, want)
+	}
+	cfg.diagnosticsOnly = true
+	want = map[string]string{"unit": "`U`Unit Ident.", "mixed": "`X`Status"}
+	if events := flushedEvents(t, cfg, []string{file}); !reflect.DeepEqual(events, want) {
+		t.Errorf("-diagnostics-only: %v, want %v", events, want)
+	}
+	// The raw timestamps are in the local time
+	hour, min, sec := second(2).Local().Clock()
+	cfg.supress = true
+	cfg.suppressStart = time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+	cfg.suppressEnd = cfg.suppressStart + time.Second
+	want = map[string]string{"mixed": "`X`Status"}
+	if events := flushedEvents(t, cfg, []string{file}); !reflect.DeepEqual(events, want) {
+		t.Errorf("-diagnostics-only -S: %v, want %v", events, want)
+	}
+}
+
+// The random initial fills of -init-buffer random come from the -seed source of the Config
+func TestProcessSeedInitialFill(t *testing.T) {
+	files := writeSpreadFiles(t, t.TempDir(), 3, 50, 200)
+	run := func(seed int64) PackageList {
+		cfg := testConfig()
+		cfg.initBuffer = initBufferRandom
+		cfg.setSeed(seed)
+		packages, _ := processPackages(t, cfg, files)
+		return packages
+	}
+
+	first := run(7)
+	if second := run(7); !reflect.DeepEqual(first, second) {
+		t.Errorf("-seed 7: %d and %d packages", len(first), len(second))
+	}
+	if other := run(8); reflect.DeepEqual(first, other) {
+		t.Error("-seed 7 and 8: the same packages")
+	}
+}
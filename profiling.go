@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Time spent per processing stage with -timings, in nanoseconds. Scanning and parsing
+// run on all the workers at once, so they add up the time of every worker.
+type Timings struct {
+	scan  int64
+	parse int64
+	sort  int64
+	write int64
+}
+
+var timings Timings
+
+// Start of a timed stage, the zero time when -timings is off
+func startTiming() time.Time {
+	if !timingsOn {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func addTiming(counter *int64, start time.Time) {
+	if timingsOn {
+		atomic.AddInt64(counter, int64(time.Since(start)))
+	}
+}
+
+func printTimings(elapsed time.Duration) {
+	fmt.Println("Timings (scan and parse summed over the workers):")
+	fmt.Println("\tScanning:\t", time.Duration(atomic.LoadInt64(&timings.scan)))
+	fmt.Println("\tParsing:\t", time.Duration(atomic.LoadInt64(&timings.parse)))
+	fmt.Println("\tSorting:\t", time.Duration(timings.sort))
+	fmt.Println("\tWriting:\t", time.Duration(timings.write))
+	fmt.Println("\tElapsed:\t", elapsed)
+}
+
+var (
+	cpuProfile   *os.File
+	profilesOnce sync.Once
+)
+
+// CPU profile for the whole run with -cpuprofile
+func startProfiling() {
+	if cpuProfileFileName == "" {
+		return
+	}
+	file, err := os.Create(cpuProfileFileName)
+	if err != nil {
+		logError("Error creating the CPU profile: %v", err)
+		return
+	}
+	if err = pprof.StartCPUProfile(file); err != nil {
+		logError("Error starting the CPU profile: %v", err)
+		file.Close()
+		return
+	}
+	cpuProfile = file
+}
+
+// Stops the CPU profile and writes the -memprofile heap profile once,
+// must run before every exit of a profiled run
+func stopProfiling() {
+	profilesOnce.Do(writeProfiles)
+}
+
+func writeProfiles() {
+	if cpuProfile != nil {
+		pprof.StopCPUProfile()
+		cpuProfile.Close()
+		cpuProfile = nil
+	}
+	if memProfileFileName == "" {
+		return
+	}
+	file, err := os.Create(memProfileFileName)
+	if err != nil {
+		logError("Error creating the memory profile: %v", err)
+		return
+	}
+	defer file.Close()
+	// Up to date allocation statistics
+	runtime.GC()
+	if err = pprof.WriteHeapProfile(file); err != nil {
+		logError("Error writing the memory profile: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Writes the packages to w as they "fire": the gaps between the package timestamps
+// are slept through, divided by speed. A gap longer than maxSleep is cut to maxSleep,
+// so hours of the overnight quiet fast-forward. Stops early when ctx is cancelled.
+func replayPackages(ctx context.Context, packages PackageList, speed float64, maxSleep time.Duration, w io.Writer) int {
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	sent := 0
+	for i, pkg := range packages {
+		if i > 0 {
+			delay := time.Duration(float64(pkg.timestamp.Sub(packages[i-1].timestamp)) / speed)
+			if delay > maxSleep {
+				delay = maxSleep
+			}
+			if delay > 0 {
+				// Whatever fired so far is out before sleeping
+				out.Flush()
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return sent
+				}
+			}
+		}
+		fmt.Fprintln(out, pkg.String())
+		sent++
+	}
+	return sent
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func replayList() PackageList {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	return PackageList{
+		Pack(start, "dev1", "Pulse", "MSO1"),
+		Pack(start.Add(2*time.Second), "dev2", "Pulse", "MSO1"),
+		Pack(start.Add(4*time.Second), "dev1", "Lock", "MSO1"),
+		// An hour of quiet, cut to the max sleep
+		Pack(start.Add(time.Hour), "dev3", "Pulse", "MSO1"),
+	}
+}
+
+// 4 seconds of gaps at the speed 100 and an hour cut to 50ms: about 90ms
+func TestReplayPackagesSpeed(t *testing.T) {
+	var out strings.Builder
+	began := time.Now()
+	sent := replayPackages(context.Background(), replayList(), 100, 50*time.Millisecond, &out)
+	elapsed := time.Since(began)
+	if sent != 4 || strings.Count(out.String(), "\n") != 4 {
+		t.Fatalf("sent %d:\n%s", sent, out.String())
+	}
+	if !strings.HasPrefix(out.String(), replayList()[0].String()+"\n") {
+		t.Errorf("first line %q, want %q", strings.SplitN(out.String(), "\n", 2)[0], replayList()[0].String())
+	}
+	if elapsed < 90*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("replay took %v, want about 90ms", elapsed)
+	}
+}
+
+func TestReplayPackagesCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	var out strings.Builder
+	// The first gap of 2s at the speed 1 is not slept through
+	if sent := replayPackages(ctx, replayList(), 1, time.Minute, &out); sent != 1 {
+		t.Errorf("sent %d before the cancel, want 1", sent)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// -segment values
+const (
+	segmentWeekday = "weekday"
+	segmentWeekend = "weekend"
+	// One segment per day of the week
+	segmentDayOfWeek = "dow"
+)
+
+func isKnownSegment(segment string) bool {
+	switch segment {
+	case "", segmentWeekday, segmentWeekend, segmentDayOfWeek:
+		return true
+	}
+	return false
+}
+
+func isWeekend(timestamp time.Time) bool {
+	weekday := timestamp.Weekday()
+	return weekday == time.Saturday || weekday == time.Sunday
+}
+
+// Segment name of the package for the -segment mode, "" when it is not in the segment
+func segmentOf(timestamp time.Time, segment string) string {
+	switch segment {
+	case segmentWeekday:
+		if !isWeekend(timestamp) {
+			return segmentWeekday
+		}
+	case segmentWeekend:
+		if isWeekend(timestamp) {
+			return segmentWeekend
+		}
+	case segmentDayOfWeek:
+		return timestamp.Weekday().String()[:3]
+	}
+	return ""
+}
+
+func packagesBySegment(packages PackageList, segment string) map[string]PackageList {
+	bySegment := make(map[string]PackageList)
+	for _, pkg := range packages {
+		if name := segmentOf(pkg.timestamp, segment); name != "" {
+			bySegment[name] = append(bySegment[name], pkg)
+		}
+	}
+	return bySegment
+}
+
+// Events per second files per segment, eventsPerSecond-<segment>-YYYY-MM-DD.csv,
+// the -PC cumulative file then compares the segments over all the dates
+func printEventsPerSecondBySegment(packages PackageList, segment string) {
+	bySegment := packagesBySegment(packages, segment)
+	names := make([]string, 0, len(bySegment))
+	for name := range bySegment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		segmentPackages := bySegment[name]
+		max, _, _ := printEventsPerSecond(segmentPackages, "eventsPerSecond-"+name)
+		fmt.Printf("Segment %s:\t packages: %d\t max per second: %d at %v\n",
+			name, len(segmentPackages), max.numberOfEvents, max.timestamp)
+	}
+}
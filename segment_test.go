@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSegmentOf(t *testing.T) {
+	saturday := time.Date(2016, 3, 5, 20, 0, 0, 0, time.UTC)
+	monday := time.Date(2016, 3, 7, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		segment   string
+		timestamp time.Time
+		want      string
+	}{
+		{segmentWeekday, saturday, ""},
+		{segmentWeekday, monday, segmentWeekday},
+		{segmentWeekend, saturday, segmentWeekend},
+		{segmentWeekend, monday, ""},
+		{segmentDayOfWeek, saturday, "Sat"},
+		{segmentDayOfWeek, monday, "Mon"},
+	}
+	for _, test := range tests {
+		if got := segmentOf(test.timestamp, test.segment); got != test.want {
+			t.Errorf("segmentOf(%v, %q) = %q, want %q", test.timestamp.Weekday(), test.segment, got, test.want)
+		}
+	}
+	if isKnownSegment("holiday") {
+		t.Error("holiday is a known segment")
+	}
+}
+
+// Saturday and Monday events, a file per segment and date
+func TestEventsPerSecondBySegment(t *testing.T) {
+	saturday := time.Date(2016, 3, 5, 20, 0, 0, 0, time.UTC)
+	monday := time.Date(2016, 3, 7, 20, 0, 0, 0, time.UTC)
+	packages := PackageList{
+		Pack(saturday, "dev1", "Pulse", "MSO1"),
+		Pack(saturday, "dev2", "Pulse", "MSO1"),
+		Pack(monday, "dev1", "Lock", "MSO1"),
+	}
+
+	tests := []struct {
+		segment string
+		files   map[string]string
+	}{
+		{segmentWeekend, map[string]string{
+			"eventsPerSecond-weekend-2016-03-05.csv": "2016-03-05 20:00:00 +0000 UTC, 2\n",
+		}},
+		{segmentWeekday, map[string]string{
+			"eventsPerSecond-weekday-2016-03-07.csv": "2016-03-07 20:00:00 +0000 UTC, 1\n",
+		}},
+		{segmentDayOfWeek, map[string]string{
+			"eventsPerSecond-Sat-2016-03-05.csv": "2016-03-05 20:00:00 +0000 UTC, 2\n",
+			"eventsPerSecond-Mon-2016-03-07.csv": "2016-03-07 20:00:00 +0000 UTC, 1\n",
+		}},
+	}
+	for _, test := range tests {
+		dir := withOutputDir(t)
+		printEventsPerSecondBySegment(packages, test.segment)
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		want := make([]string, 0, len(test.files))
+		for name, content := range test.files {
+			want = append(want, name)
+			if got := readOutput(t, dir, name); got != content {
+				t.Errorf("-segment %s %s:\n%s\nwant:\n%s", test.segment, name, got, content)
+			}
+		}
+		if len(names) != len(want) {
+			t.Errorf("-segment %s files %s, want %s", test.segment, strings.Join(names, " "), strings.Join(want, " "))
+		}
+	}
+}
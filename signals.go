@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit code of a run stopped by SIGINT/SIGTERM, after the partial results were written
+const exitInterrupted = 130
+
+// Cancels the returned context on the first SIGINT/SIGTERM, so the processing stops
+// taking new files and main goes on flushing what was parsed so far through the
+// same output path as a complete run. A second signal exits at once.
+func withInterrupt(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			logWarn("Received %v, stopping and writing the partial results", sig)
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+		if sig, ok := <-signals; ok {
+			logError("Received %v again, exiting without output", sig)
+			exit(exitInterrupted)
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
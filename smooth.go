@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const smoothedFileName = "eventsPerSecondSmoothed.csv"
+
+// N second trailing moving average of the per second counts, handed to emit second
+// by second. The seconds without events count as zeros, up to -max-gap of them in a row:
+// a longer gap is left out and the window starts over after it. The first N-1 seconds
+// of a window are averaged over the seconds so far.
+func movingAverage(orderedEventsPerSecond TimepointTypeList, window int, maxGap time.Duration, emit func(points TimepointType, average float64)) {
+	counts := make([]int, window)
+	sum, seconds := 0, 0
+	add := func(points TimepointType) {
+		slot := seconds % window
+		sum += points.numberOfEvents - counts[slot]
+		counts[slot] = points.numberOfEvents
+		seconds++
+		averaged := window
+		if seconds < window {
+			averaged = seconds
+		}
+		emit(points, float64(sum)/float64(averaged))
+	}
+
+	for i, points := range orderedEventsPerSecond {
+		if i > 0 {
+			previous := orderedEventsPerSecond[i-1].timestamp
+			if gap := points.timestamp.Sub(previous) - time.Second; maxGap > 0 && gap > maxGap {
+				counts = make([]int, window)
+				sum, seconds = 0, 0
+			} else {
+				for timestamp := previous.Add(time.Second); timestamp.Before(points.timestamp); timestamp = timestamp.Add(time.Second) {
+					add(TimepointType{timestamp, 0})
+				}
+			}
+		}
+		add(points)
+	}
+}
+
+func printSmoothedEventsPerSecond(packages PackageList, window int) {
+	w, err := createOutputFile(smoothedFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, fmt.Sprintf("timestamp, events, average%d", window))
+	movingAverage(eventsPerSecondList(packages), window, maxGap, func(points TimepointType, average float64) {
+		fmt.Fprintf(w, "%s, %d, %.3f\n", timepointKey(points.timestamp), points.numberOfEvents, average)
+	})
+	w.Close()
+}
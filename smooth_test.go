@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMovingAverage(t *testing.T) {
+	start := time.Date(2016, 3, 1, 10, 0, 0, 0, time.UTC)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	tests := []struct {
+		name   string
+		points TimepointTypeList
+		window int
+		maxGap time.Duration
+		want   string
+	}{
+		{"no gaps", TimepointTypeList{{second(0), 3}, {second(1), 6}, {second(2), 9}}, 2, time.Hour,
+			"0:3 3.000, 1:6 4.500, 2:9 7.500"},
+		{"gap filled with zeros", TimepointTypeList{{second(0), 6}, {second(3), 3}}, 3, time.Hour,
+			"0:6 6.000, 1:0 3.000, 2:0 2.000, 3:3 1.000"},
+		{"gap over max-gap starts over", TimepointTypeList{{second(0), 6}, {second(10), 4}, {second(11), 2}}, 3, 5 * time.Second,
+			"0:6 6.000, 10:4 4.000, 11:2 3.000"},
+		{"unlimited gap", TimepointTypeList{{second(0), 4}, {second(3), 4}}, 2, 0,
+			"0:4 4.000, 1:0 2.000, 2:0 0.000, 3:4 2.000"},
+		{"empty", nil, 3, time.Hour, ""},
+	}
+	for _, test := range tests {
+		got := ""
+		movingAverage(test.points, test.window, test.maxGap, func(points TimepointType, average float64) {
+			if got != "" {
+				got += ", "
+			}
+			got += fmt.Sprintf("%d:%d %.3f", int(points.timestamp.Sub(start)/time.Second), points.numberOfEvents, average)
+		})
+		if got != test.want {
+			t.Errorf("%s: %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// Years apart, only the gaps up to -max-gap are written out
+func TestMovingAverageLongSpan(t *testing.T) {
+	start := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	points := TimepointTypeList{{start, 1}, {start.AddDate(3, 0, 0), 1}}
+	rows := 0
+	movingAverage(points, 60, time.Hour, func(TimepointType, float64) { rows++ })
+	if rows != 2 {
+		t.Errorf("%d rows for two seconds three years apart", rows)
+	}
+}
+
+// The packages counted per second, the gap second written as a zero row
+func TestPrintSmoothedEventsPerSecond(t *testing.T) {
+	dir := withOutputDir(t)
+	saved := maxGap
+	defer func() { maxGap = saved }()
+	maxGap = time.Hour
+
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	packages := PackageList{
+		Pack(start, "dev1", "Pulse", "MSO1"),
+		Pack(start, "dev2", "Pulse", "MSO1"),
+		Pack(start.Add(2*time.Second), "dev1", "Lock", "MSO1"),
+	}
+	printSmoothedEventsPerSecond(packages, 2)
+	want := "timestamp, events, average2\n" +
+		"2016-03-01 20:00:00 +0000 UTC, 2, 2.000\n" +
+		"2016-03-01 20:00:01 +0000 UTC, 0, 1.000\n" +
+		"2016-03-01 20:00:02 +0000 UTC, 1, 0.500\n"
+	if got := readOutput(t, dir, smoothedFileName); got != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", smoothedFileName, got, want)
+	}
+}
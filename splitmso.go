@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Same stats printEventsPerSecond reports, without writing any file
+func eventsPerSecondStats(orderedEventsPerSecond TimepointTypeList) (max TimepointType, avg int, total int) {
+	for _, points := range orderedEventsPerSecond {
+		if points.numberOfEvents > max.numberOfEvents {
+			max = points
+		}
+		avg += points.numberOfEvents
+	}
+	if len(orderedEventsPerSecond) > 0 {
+		avg = avg / len(orderedEventsPerSecond)
+	}
+	total = len(orderedEventsPerSecond)
+	return
+}
+
+// Writes every MSO outputs into outputDir/<mso>, the returned stats are for all the MSOs together
+func printOutputsByMso(packages PackageList, eventsLog OrderedVodLogList, errors []ErrorLogEntry) (max TimepointType, avg int, total int) {
+	msoPackages := packagesByMso(packages)
+	msoEvents := make(map[string]OrderedVodLogList)
+	for _, event := range eventsLog {
+		msoEvents[event.mso] = append(msoEvents[event.mso], event)
+	}
+	msoErrors := make(map[string][]ErrorLogEntry)
+	for _, entry := range errors {
+		mso := msoName(entry.fileName)
+		msoErrors[mso] = append(msoErrors[mso], entry)
+	}
+
+	msoSet := make(map[string]bool)
+	for mso := range msoPackages {
+		msoSet[mso] = true
+	}
+	for mso := range msoEvents {
+		msoSet[mso] = true
+	}
+	for mso := range msoErrors {
+		msoSet[mso] = true
+	}
+	msos := make([]string, 0, len(msoSet))
+	for mso := range msoSet {
+		msos = append(msos, mso)
+	}
+	sort.Strings(msos)
+
+	// All the output files are created under outputDir, point it to the MSO one at a time
+	baseDir := outputDir
+	defer func() { outputDir = baseDir }()
+	for _, mso := range msos {
+		outputDir = filepath.Join(baseDir, mso)
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			logError("Error creating output directory: %v", err)
+			continue
+		}
+		logDebug("Writing %s outputs into %s", mso, outputDir)
+		printOutputs(msoPackages[mso], msoEvents[mso], msoErrors[mso])
+	}
+
+	return eventsPerSecondStats(eventsPerSecondList(packages))
+}
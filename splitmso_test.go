@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Each MSO of the two file fixture gets its own subdirectory, with its packages only
+func TestPrintOutputsByMso(t *testing.T) {
+	dir := withOutputDir(t)
+	savedFormat, savedExt, savedName := outputFormat, outputExtensionOverride, outputFileName
+	defer func() { outputFormat, outputExtensionOverride, outputFileName = savedFormat, savedExt, savedName }()
+	outputFormat, outputExtensionOverride, outputFileName = csvFormat, "", "output"
+
+	input := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	payload := strings.Repeat("AB", 200)
+	var files []string
+	for _, mso := range []string{"MSO1", "MSO2"} {
+		var lines []string
+		for i := 0; i < 10; i++ {
+			lines = append(lines, rawLine(t, mso+"-dev", "4D", start.Add(time.Duration(i)*time.Second), payload))
+		}
+		files = append(files, writeInput(t, input, "a_"+mso+".raw", lines...))
+	}
+	packages, _ := processPackages(t, testConfig(), files)
+	if len(packages) == 0 {
+		t.Fatal("no packages")
+	}
+	printOutputsByMso(packages, nil, nil)
+	if outputDir != dir {
+		t.Errorf("outputDir %s after the split, want %s back", outputDir, dir)
+	}
+
+	for _, mso := range []string{"MSO1", "MSO2"} {
+		content := readOutput(t, dir, filepath.Join(mso, "output.csv"))
+		lines := strings.Split(strings.TrimSpace(content), "\n")
+		if len(lines) != len(packages)/2 {
+			t.Errorf("%s: %d packages, want %d", mso, len(lines), len(packages)/2)
+		}
+		for _, line := range lines {
+			if !strings.Contains(line, mso+"-dev") {
+				t.Errorf("%s/output.csv: %q", mso, line)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, mso, fmt.Sprintf("eventsPerSecond-%s.csv", start.Format("2006-01-02")))); err != nil {
+			t.Errorf("%s events per second: %v", mso, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output.csv")); err == nil {
+		t.Error("output.csv in -outdir, want the MSO subdirectories only")
+	}
+}
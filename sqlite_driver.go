@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// Pure Go SQLite driver for the -db sink, registers itself as "sqlite"
+import _ "modernc.org/sqlite"
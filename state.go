@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// File identity for the incremental processing
+type FileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Files processed by the previous runs, keyed by path
+type ProcessingState struct {
+	Files map[string]FileState `json:"files"`
+}
+
+var (
+	processingState   *ProcessingState
+	stateSkippedFiles int
+)
+
+func newProcessingState() *ProcessingState {
+	return &ProcessingState{Files: make(map[string]FileState)}
+}
+
+// Missing state file means the first run, everything gets processed
+func loadState(fileName string, reset bool) *ProcessingState {
+	state := newProcessingState()
+	if reset {
+		return state
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn("Error reading state file: %v", err)
+		}
+		return state
+	}
+	if err = json.Unmarshal(data, state); err != nil {
+		logError("Error parsing state file %s: %v", fileName, err)
+		exit(-1)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]FileState)
+	}
+	return state
+}
+
+func (state *ProcessingState) save(fileName string) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		logError("Error saving state: %v", err)
+		return
+	}
+	if err = os.WriteFile(fileName, data, 0644); err != nil {
+		logError("Error saving state: %v", err)
+	}
+}
+
+func currentFileState(path string) (FileState, bool) {
+	info, err := statInput(path)
+	if err != nil {
+		return FileState{}, false
+	}
+	return FileState{info.Size(), info.ModTime().UTC()}, true
+}
+
+// Same size and modification time as recorded means the file is unchanged
+func (state *ProcessingState) isProcessed(path string) bool {
+	recorded, ok := state.Files[path]
+	if !ok {
+		return false
+	}
+	current, ok := currentFileState(path)
+	return ok && current.Size == recorded.Size && current.ModTime.Equal(recorded.ModTime)
+}
+
+// The size is of the bytes actually read, a file grown since the open is processed again
+func (state *ProcessingState) markProcessed(path string, read FileState) {
+	state.Files[path] = read
+}
+
+// Input bytes read from the file
+type countingReader struct {
+	reader io.Reader
+	bytes  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (state *ProcessingState) filterProcessed(fileList []string) []string {
+	newFiles := []string{}
+	for _, path := range fileList {
+		if state.isProcessed(path) {
+			stateSkippedFiles++
+			logDebug("Skipped, already processed: %s", path)
+			continue
+		}
+		newFiles = append(newFiles, path)
+	}
+	return newFiles
+}
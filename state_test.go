@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func processWithState(t *testing.T, cfg *Config, files ...string) *ProcessingState {
+	t.Helper()
+	result, err := Process(context.Background(), cfg, files, nil, newBufferState(), time.Now())
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	state := newProcessingState()
+	for fileName, read := range result.processed {
+		state.markProcessed(fileName, read)
+	}
+	return state
+}
+
+func TestStateRecordsBytesRead(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	fileName := writeInput(t, t.TempDir(), "a_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		rawLine(t, "dev2", "43", start.Add(time.Second), "0A0B"))
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := processWithState(t, testConfig(), fileName)
+	recorded, ok := state.Files[fileName]
+	if !ok || recorded.Size != info.Size() || !recorded.ModTime.Equal(info.ModTime().UTC()) {
+		t.Fatalf("state of %s = %+v, %v, want the size %d and time %v", fileName, recorded, ok, info.Size(), info.ModTime())
+	}
+	if !state.isProcessed(fileName) {
+		t.Error("isProcessed of the unchanged file = false")
+	}
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(rawLine(t, "dev3", "50", start.Add(2*time.Second), "") + "\n")
+	file.Close()
+	if state.isProcessed(fileName) {
+		t.Error("isProcessed of the grown file = true")
+	}
+}
+
+func TestStateSkipsReadErrors(t *testing.T) {
+	previous := maxLineSize
+	maxLineSize = 64
+	defer func() { maxLineSize = previous }()
+
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	good := writeInput(t, dir, "a_MSO1.raw", rawLine(t, "dev1", "50", start, ""))
+	bad := writeInput(t, dir, "b_MSO1.raw", rawLine(t, "dev1", "50", start, ""), "dev2 "+strings.Repeat("43", 64))
+
+	state := processWithState(t, testConfig(), good, bad)
+	if _, ok := state.Files[good]; !ok {
+		t.Errorf("%s not in the state", good)
+	}
+	if recorded, ok := state.Files[bad]; ok {
+		t.Errorf("%s with a read error in the state: %+v", bad, recorded)
+	}
+}
+
+// A file cut short by -head is taken again by the next run, one shorter than -head is done
+func TestStateSkipsHeadCut(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	long := writeInput(t, dir, "a_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		rawLine(t, "dev1", "50", start.Add(time.Second), ""),
+		rawLine(t, "dev1", "50", start.Add(2*time.Second), ""))
+	short := writeInput(t, dir, "b_MSO1.raw", rawLine(t, "dev2", "50", start, ""))
+
+	cfg := testConfig()
+	cfg.headLines = 2
+	state := processWithState(t, cfg, long, short)
+	if recorded, ok := state.Files[long]; ok {
+		t.Errorf("%s cut by -head 2 in the state: %+v", long, recorded)
+	}
+	if !state.isProcessed(short) {
+		t.Errorf("%s of one line not processed with -head 2", short)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+// A mode of the tool as a subcommand, e.g. csbufferanalizer vod -d <dir>.
+// The subcommand sets its mode flags itself, the mode flags of the other modes are not accepted.
+// Without a subcommand the flat flags work as before.
+type Subcommand struct {
+	name        string
+	description string
+	// Mode flag values the subcommand stands for
+	modeFlags map[string]string
+}
+
+var subcommands = map[string]Subcommand{
+	"analyze":  {"analyze", "Buffer simulation: packages and events per second (the default mode)", nil},
+	"vod":      {"vod", "Buffer simulation and the VOD activity log", map[string]string{"VOD": "true"}},
+	"events":   {"events", "Buffer simulation and the full events sequence log", map[string]string{"L": "true"}},
+	"validate": {"validate", "Input validation only, json report on stdout", map[string]string{"validate": "true"}},
+}
+
+// Flags selecting a mode, replaced by the subcommands
+var modeFlagNames = []string{"VOD", "L", "validate", "count-only", "merge", "encode", "errors-only", "list-codes"}
+
+// Subcommand of the command line, nil for the flat flags
+func subcommandOf(args []string) *Subcommand {
+	if len(args) < 2 {
+		return nil
+	}
+	if command, ok := subcommands[args[1]]; ok {
+		return &command
+	}
+	return nil
+}
+
+// Own flag set for the subcommand, to be set before the flags are defined
+func (command *Subcommand) newFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(appName+" "+command.name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "%s %s: %s\n", appName, command.name, command.description)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// Parses the subcommand arguments into flag.CommandLine and sets the mode flags
+func (command *Subcommand) parse(args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	for _, name := range modeFlagNames {
+		if isFlagSet(name) {
+			return fmt.Errorf("-%s is a mode flag, not accepted by the %s subcommand", name, command.name)
+		}
+	}
+	for name, value := range command.modeFlags {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printSubcommands() {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("Subcommands:")
+	for _, name := range names {
+		fmt.Printf("\tprompt$>%s %s [flags]\t %s\n", appName, name, subcommands[name].description)
+	}
+}
+
+// Subcommand parse error, prints the subcommand usage
+func subcommandUsage(err error) {
+	fmt.Println(err)
+	flag.CommandLine.Usage()
+	exit(-1)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Machine readable run summary, written with -summary-json
+type Summary struct {
+	Files            int            `json:"files"`
+	FilesLeftOut     int            `json:"filesLeftOut"`
+	SampleRate       float64        `json:"sampleRate"`
+	Devices          int            `json:"devices"`
+	TotalEvents      int            `json:"totalEvents"`
+	ValidEvents      int            `json:"validEvents"`
+	TotalPackages    int            `json:"totalPackages"`
+	ParseErrors      int            `json:"parseErrors"`
+	ErrorsByCategory map[string]int `json:"errorsByCategory"`
+	TotalBytes       int            `json:"totalBytes"`
+	AverageEventSize float64        `json:"averageEventSize"`
+	BytesPerDevice   map[string]int `json:"bytesPerDevice"`
+	MaxPerSecond     int            `json:"maxPerSecond"`
+	MaxPerSecondAt   time.Time      `json:"maxPerSecondAt"`
+	// Integer average over the active seconds, kept for the existing readers
+	AveragePerSecond       int     `json:"averagePerSecond"`
+	AveragePerActiveSecond float64 `json:"averagePerActiveSecond"`
+	// Idle seconds between the first and the last package included
+	AveragePerSpanSecond float64 `json:"averagePerSpanSecond"`
+	SpanSeconds          int     `json:"spanSeconds"`
+	Elapsed              string  `json:"elapsed"`
+}
+
+func averageSize(bytes, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(bytes) / float64(count)
+}
+
+func printJsonSummary(fileName string, summary Summary) {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	if err = os.WriteFile(fileName, data, 0644); err != nil {
+		logError("%v", err)
+	}
+}
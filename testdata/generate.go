@@ -0,0 +1,53 @@
+//go:build ignore
+
+// Generates an -encode spec of synthetic events for the benchmark runs, e.g.
+//
+//	go run testdata/generate.go -events 1000000 -devices 5000 > spec.csv
+//	csbufferanalizer -encode spec.csv > big_MSO1.raw
+//	csbufferanalizer -f big_MSO1.raw -timings
+//
+// The events are spread evenly over the -hours from -start, with random devices,
+// codes and payload lengths. The same -seed gives the same spec.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Codes of the generated events, weighted towards the ones busy in the field data
+var codes = []string{"41", "43", "43", "43", "63", "63", "45", "48", "48", "49", "4B", "4B", "4B", "4B", "53", "56", "58"}
+
+func main() {
+	events := flag.Int("events", 10000, "Number of the events")
+	devices := flag.Int("devices", 100, "Number of the devices")
+	hours := flag.Int("hours", 1, "Hours the events are spread over")
+	start := flag.String("start", "2016-03-01T00:00:00Z", "First event timestamp, RFC3339")
+	maxPayload := flag.Int("max-payload", 160, "Longest payload, in bytes")
+	seed := flag.Int64("seed", 1, "Random seed")
+	flag.Parse()
+
+	startTime, err := time.Parse(time.RFC3339, *start)
+	if err != nil || *events <= 0 || *devices <= 0 || *hours <= 0 || *maxPayload < 0 {
+		fmt.Fprintln(os.Stderr, "Wrong arguments")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	random := rand.New(rand.NewSource(*seed))
+	step := time.Duration(*hours) * time.Hour / time.Duration(*events)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	fmt.Fprintln(out, "timestamp, deviceId, eventCode, payload")
+	for i := 0; i < *events; i++ {
+		timestamp := startTime.Add(time.Duration(i) * step)
+		payload := make([]byte, random.Intn(*maxPayload+1))
+		random.Read(payload)
+		fmt.Fprintf(out, "%s, dev%d, %s, %X\n", timestamp.Format(time.RFC3339),
+			random.Intn(*devices), codes[random.Intn(len(codes))], payload)
+	}
+}
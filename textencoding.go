@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// -text-encoding values, the encoding of the text in the clickstring payloads
+const (
+	asciiText   = "ascii"
+	utf16leText = "utf16le"
+	utf16beText = "utf16be"
+	// ASCII, or UTF-16 told by the null bytes around the characters
+	autoText = "auto"
+)
+
+func parseTextEncoding(name string) (string, error) {
+	switch name = strings.ToLower(name); name {
+	case asciiText, utf16leText, utf16beText, autoText:
+		return name, nil
+	}
+	return "", fmt.Errorf("Unknown text encoding: %s, expected ascii, utf16le, utf16be or auto", name)
+}
+
+// Whether the payload character at the hex offset is c. A UTF-16 character takes
+// two bytes, the null byte is after the character in LE and before it in BE.
+func isPayloadChar(clickString string, offset int, c byte) bool {
+	hexAt := func(start int) string {
+		if start+2 > len(clickString) {
+			return ""
+		}
+		return clickString[start : start+2]
+	}
+
+	switch textEncoding {
+	case utf16leText:
+		return isHexChar(hexAt(offset), c) && hexAt(offset+2) == "00"
+	case utf16beText:
+		return hexAt(offset) == "00" && isHexChar(hexAt(offset+2), c)
+	case autoText:
+		// LE reads as ASCII, BE has the null byte first
+		return isHexChar(hexAt(offset), c) ||
+			(hexAt(offset) == "00" && isHexChar(hexAt(offset+2), c))
+	}
+	return isHexChar(hexAt(offset), c)
+}
+
+// Text of the payload bytes in the -text-encoding, in the auto mode the null bytes
+// of the UTF-16 text and of the padding are dropped
+func decodeText(raw []byte) string {
+	switch textEncoding {
+	case utf16leText, utf16beText:
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 0; i+1 < len(raw); i += 2 {
+			if textEncoding == utf16leText {
+				units = append(units, uint16(raw[i])|uint16(raw[i+1])<<8)
+			} else {
+				units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+			}
+		}
+		return string(utf16.Decode(units))
+	case autoText:
+		return strings.ReplaceAll(string(raw), "\x00", "")
+	}
+	return string(raw)
+}
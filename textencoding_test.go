@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func withTextEncoding(t *testing.T, encoding string) {
+	t.Helper()
+	saved := textEncoding
+	t.Cleanup(func() { textEncoding = saved })
+	textEncoding = encoding
+}
+
+func TestParseTextEncoding(t *testing.T) {
+	for name, want := range map[string]string{"ascii": asciiText, "UTF16LE": utf16leText, "utf16be": utf16beText, "Auto": autoText} {
+		if got, err := parseTextEncoding(name); got != want || err != nil {
+			t.Errorf("parseTextEncoding(%s) = %s, %v, want %s", name, got, err, want)
+		}
+	}
+	if _, err := parseTextEncoding("latin1"); err == nil {
+		t.Error("parseTextEncoding(latin1), want an error")
+	}
+}
+
+// 'V' is 0x56, the null byte after it in LE and before it in BE
+func TestIsPayloadChar(t *testing.T) {
+	tests := []struct {
+		encoding    string
+		clickString string
+		want        bool
+	}{
+		{asciiText, "4956", true},
+		{asciiText, "490056", false},
+		{utf16leText, "495600", true},
+		{utf16leText, "4956", false},
+		{utf16leText, "49560", false},
+		{utf16leText, "490056", false},
+		{utf16beText, "490056", true},
+		{utf16beText, "495600", false},
+		{utf16beText, "4900", false},
+		{autoText, "4956", true},
+		{autoText, "495600", true},
+		{autoText, "490056", true},
+		{autoText, "490057", false},
+		{autoText, "4900", false},
+	}
+	for _, test := range tests {
+		withTextEncoding(t, test.encoding)
+		if got := isPayloadChar(test.clickString, 2, 'V'); got != test.want {
+			t.Errorf("%s: isPayloadChar(%s, 2, V) = %v, want %v", test.encoding, test.clickString, got, test.want)
+		}
+	}
+}
+
+// Odd length UTF-16 drops the last byte, auto drops the null bytes
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		encoding string
+		raw      string
+		want     string
+	}{
+		{asciiText, "HBO\x00", "HBO\x00"},
+		{utf16leText, "H\x00B\x00O\x00", "HBO"},
+		{utf16leText, "\xe9\x00", "é"},
+		{utf16leText, "H\x00B", "H"},
+		{utf16beText, "\x00H\x00B\x00O", "HBO"},
+		{utf16beText, "\x00\xe9", "é"},
+		{utf16beText, "\x00H\x00", "H"},
+		{utf16beText, "", ""},
+		{autoText, "H\x00B\x00O\x00\x00\x00", "HBO"},
+		{autoText, "\x00H\x00B", "HB"},
+		{autoText, "HBO", "HBO"},
+	}
+	for _, test := range tests {
+		withTextEncoding(t, test.encoding)
+		if got := decodeText([]byte(test.raw)); got != test.want {
+			t.Errorf("%s: decodeText(%q) = %q, want %q", test.encoding, test.raw, got, test.want)
+		}
+	}
+}
+
+// The Info Screen type V marker of the UTF-16 firmwares is VOD activity
+func TestVodActivityUtf16Marker(t *testing.T) {
+	const timestamp = "56A7B3C0"
+	tests := []struct {
+		encoding    string
+		clickString string
+		want        bool
+	}{
+		{asciiText, "49" + timestamp + "56", true},
+		{asciiText, "49" + timestamp + "0056", false},
+		{utf16leText, "49" + timestamp + "5600", true},
+		{utf16beText, "49" + timestamp + "0056", true},
+		{utf16beText, "49" + timestamp + "5600", false},
+		{autoText, "49" + timestamp + "0056", true},
+		{autoText, "49" + timestamp + "5600", true},
+	}
+	for _, test := range tests {
+		withTextEncoding(t, test.encoding)
+		found, entry := checkAndLogForVodActivity("`I`Info", time.Now(), "", "dev1", 0, test.clickString, 0, LineSource{"MSO1", "a_MSO1.raw", 1})
+		if found != test.want || (found && entry.eventcode != "`I`Info / Type V") {
+			t.Errorf("%s: %s = %v %q, want %v", test.encoding, test.clickString, found, entry.eventcode, test.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const topSecondsFileName = "topSeconds.csv"
+
+// The n busiest seconds, the earliest first on ties
+func topSeconds(orderedEventsPerSecond TimepointTypeList, n int) TimepointTypeList {
+	top := make(TimepointTypeList, len(orderedEventsPerSecond))
+	copy(top, orderedEventsPerSecond)
+	// The list is in time order, the stable sort keeps it for the same counts
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].numberOfEvents > top[j].numberOfEvents
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+func printTopSeconds(packages PackageList, n int) {
+	top := topSeconds(eventsPerSecondList(packages), n)
+
+	w, err := createOutputFile(topSecondsFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "timestamp, events")
+	fmt.Printf("Top %d seconds:\n", n)
+	for _, points := range top {
+		fmt.Fprintf(w, "%s, %d\n", timepointKey(points.timestamp), points.numberOfEvents)
+		fmt.Printf("\t%v:\t %d\n", points.timestamp, points.numberOfEvents)
+	}
+	w.Close()
+}
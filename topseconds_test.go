@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The busiest seconds first, the earlier second first on the same count
+func TestTopSeconds(t *testing.T) {
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	perSecond := TimepointTypeList{
+		{second(0), 1},
+		{second(1), 5},
+		{second(2), 3},
+		{second(3), 5},
+		{second(4), 2},
+	}
+	want := TimepointTypeList{
+		{second(1), 5},
+		{second(3), 5},
+		{second(2), 3},
+	}
+	if got := topSeconds(perSecond, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("top 3 %v, want %v", got, want)
+	}
+	if perSecond[1].numberOfEvents != 5 || perSecond[2].numberOfEvents != 3 {
+		t.Errorf("the time ordered list was changed: %v", perSecond)
+	}
+	if got := topSeconds(perSecond, 10); len(got) != len(perSecond) {
+		t.Errorf("top 10 of %d seconds: %d", len(perSecond), len(got))
+	}
+}
+
+func TestPrintTopSeconds(t *testing.T) {
+	dir := withOutputDir(t)
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	packages := PackageList{
+		Pack(start, "dev1", "Pulse", "MSO1"),
+		Pack(start.Add(time.Second), "dev1", "Lock", "MSO1"),
+		Pack(start.Add(time.Second), "dev2", "Pulse", "MSO1"),
+	}
+	printTopSeconds(packages, 1)
+	if got, want := readOutput(t, dir, topSecondsFileName), "timestamp, events\n2016-03-01 20:00:01 +0000 UTC, 2\n"; got != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", topSecondsFileName, got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	unknownCodesFileName = "unknownCodes.csv"
+	// Sample lines kept per unknown code
+	maxUnknownCodeSamples = 3
+)
+
+// Occurrences of a code not in the commands list, to extend the table from
+type UnknownCode struct {
+	events  int
+	samples []string
+}
+
+// Unknown codes of the CLI run by the hex code, from the Process result
+var unknownCodes = make(map[string]*UnknownCode)
+
+func addUnknownCode(codes map[string]*UnknownCode, code string, events int, samples ...string) {
+	unknown, ok := codes[code]
+	if !ok {
+		unknown = &UnknownCode{}
+		codes[code] = unknown
+	}
+	unknown.events += events
+	for _, sample := range samples {
+		if len(unknown.samples) < maxUnknownCodeSamples {
+			unknown.samples = append(unknown.samples, sample)
+		}
+	}
+}
+
+func mergeUnknownCodes(into, codes map[string]*UnknownCode) {
+	for code, unknown := range codes {
+		addUnknownCode(into, code, unknown.events, unknown.samples...)
+	}
+}
+
+// Code of the unknown event of the line: from the UNKNOWN-<hex> name,
+// or the first clickstring byte with -strict-codes
+func unknownCodeOfLine(line string, eventCode string, codeOffset int) string {
+	if eventCode != "" {
+		return strings.TrimPrefix(eventCode, unknownCodePrefix)
+	}
+	clickString := lineClickString(line, codeOffset)
+	if len(clickString) > 2 {
+		clickString = clickString[:2]
+	}
+	return strings.ToUpper(clickString)
+}
+
+// Unknown codes, the most frequent first, with the sample lines
+func printUnknownCodes() {
+	if len(unknownCodes) == 0 {
+		return
+	}
+
+	codes := make([]string, 0, len(unknownCodes))
+	for code := range unknownCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if unknownCodes[codes[i]].events != unknownCodes[codes[j]].events {
+			return unknownCodes[codes[i]].events > unknownCodes[codes[j]].events
+		}
+		return codes[i] < codes[j]
+	})
+
+	w, err := createOutputFile(unknownCodesFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "code, events, samples")
+	fmt.Println("Unknown event codes:")
+	for _, code := range codes {
+		unknown := unknownCodes[code]
+		fmt.Fprintf(w, "%s, %d, %s\n", code, unknown.events, strings.Join(unknown.samples, " | "))
+		fmt.Printf("\t%s:\t %d\t e.g. %s\n", code, unknown.events, unknown.samples[0])
+	}
+	w.Close()
+}
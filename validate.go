@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Malformed line samples kept per file in the validation report
+const maxValidationSamples = 20
+
+// Validation outcome of a single input file. The lines are the event lines parsed,
+// the blank lines and the csv header are not counted.
+type FileValidation struct {
+	File         string         `json:"file"`
+	Lines        int            `json:"lines"`
+	Valid        int            `json:"valid"`
+	Errors       int            `json:"errors"`
+	Malformed    int            `json:"malformed"`
+	UnknownCodes int            `json:"unknownCodes"`
+	OutOfRange   int            `json:"outOfRangeTimestamps"`
+	Reasons      map[string]int `json:"reasons"`
+	Samples      []errorRecord  `json:"samples"`
+	ReadError    string         `json:"readError,omitempty"`
+}
+
+// The -validate report, a data quality gate: passed is false when the error rate
+// over all the files is above maxErrorRate
+type ValidationReport struct {
+	Files        []FileValidation `json:"files"`
+	Lines        int              `json:"lines"`
+	Valid        int              `json:"valid"`
+	Errors       int              `json:"errors"`
+	ErrorRate    float64          `json:"errorRate"`
+	MaxErrorRate float64          `json:"maxErrorRate"`
+	Passed       bool             `json:"passed"`
+}
+
+func validateFile(cfg *Config, fileName string, now time.Time) FileValidation {
+	validation := FileValidation{File: fileName, Reasons: make(map[string]int), Samples: []errorRecord{}}
+
+	file, err := openInput(fileName)
+	if err != nil {
+		validation.ReadError = err.Error()
+		return validation
+	}
+	defer file.Close()
+
+	mso := msoName(fileName)
+	scanner := newLineScanner(file)
+	lineNo := 0
+	var columns *CsvColumns
+	if cfg.inputFormat == csvInput && scanner.Scan() {
+		lineNo++
+		if columns, err = parseCsvHeader(scanner.Text()); err != nil {
+			validation.ReadError = err.Error()
+			return validation
+		}
+	}
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if isBlankLine(line) {
+			continue
+		}
+		validation.Lines++
+		_, _, _, _, eventCode, err := parseInputEvent(cfg, line, columns, nil, LineSource{mso, fileName, lineNo}, now)
+		if err == nil {
+			validation.Valid++
+			if strings.HasPrefix(eventCode, unknownCodePrefix) {
+				// Kept as UNKNOWN-<hex> without -strict-codes, valid but still reported
+				validation.UnknownCodes++
+			}
+			continue
+		}
+
+		validation.Errors++
+		category := errorCategory(err)
+		switch category {
+		case codeError:
+			validation.UnknownCodes++
+		case dateError:
+			validation.OutOfRange++
+		default:
+			validation.Malformed++
+		}
+		// The message without the offending value
+		reason, _, _ := strings.Cut(err.Error(), ":")
+		validation.Reasons[reason]++
+		if len(validation.Samples) < maxValidationSamples {
+			validation.Samples = append(validation.Samples, errorRecord{fileName, lineNo, category.String(), err.Error(), cfg.anonymizeLine(line, columns)})
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		validation.ReadError = err.Error()
+	}
+	return validation
+}
+
+// Parses every line of the files without the simulation and writes the json report to w.
+// Unreadable files count as failed, whatever the error rate.
+func validateFiles(cfg *Config, files []string, maxErrorRate float64, now time.Time, w io.Writer) (ValidationReport, error) {
+	report := ValidationReport{Files: make([]FileValidation, 0, len(files)), MaxErrorRate: maxErrorRate, Passed: true}
+	for _, fileName := range files {
+		logDebug("Validating: %s", fileName)
+		validation := validateFile(cfg, fileName, now)
+		report.Files = append(report.Files, validation)
+		report.Lines += validation.Lines
+		report.Valid += validation.Valid
+		report.Errors += validation.Errors
+		if validation.ReadError != "" {
+			report.Passed = false
+		}
+	}
+	if report.Lines > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Lines)
+	}
+	if report.ErrorRate > maxErrorRate {
+		report.Passed = false
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return report, fmt.Errorf("Error writing the validation report: %v", err)
+	}
+	return report, nil
+}
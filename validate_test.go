@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// The error rate is over the event lines, the blank lines and the csv header left out
+func TestValidateFilesErrorRate(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2016, 3, 1, 20, 0, 0, 0, time.UTC)
+	raw := writeInput(t, dir, "a_MSO1.raw",
+		rawLine(t, "dev1", "50", start, ""),
+		"",
+		"   ",
+		"dev2 not a clickstring")
+	csv := writeInput(t, dir, "b_MSO1.csv",
+		"timestamp, deviceId, eventCode, eventSize",
+		"",
+		"not, an, event",
+		start.Format(csvTimeLayout)+", dev1, `P`Pulse, 5")
+
+	for _, test := range []struct {
+		name   string
+		format string
+		file   string
+		lineNo int
+	}{
+		{"raw", rawInput, raw, 4},
+		{"-in-format csv", csvInput, csv, 3},
+	} {
+		cfg := testConfig()
+		cfg.inputFormat = test.format
+		var out bytes.Buffer
+		report, err := validateFiles(cfg, []string{test.file}, 0.4, time.Now(), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Lines != 2 || report.Valid != 1 || report.Errors != 1 || report.ErrorRate != 0.5 || report.Passed {
+			t.Errorf("%s: %d lines, %d valid, %d errors, rate %v, passed %v, want 2 lines at 0.5 failed",
+				test.name, report.Lines, report.Valid, report.Errors, report.ErrorRate, report.Passed)
+		}
+		if samples := report.Files[0].Samples; len(samples) != 1 || samples[0].LineNo != test.lineNo {
+			t.Errorf("%s: samples %+v, want the line %d", test.name, samples, test.lineNo)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const workerStatsFileName = "workerStats.csv"
+
+// Time and files of a single pool worker, written by the worker only
+type WorkerStats struct {
+	files int
+	busy  time.Duration
+	// Waiting for a file, for the earlier files buffer steps, or for the collector to take the result
+	idle time.Duration
+}
+
+// Utilization of the processing pool, with -workers-metrics
+type PoolStats struct {
+	workers []WorkerStats
+	// Results done and not yet taken by the single collector, and the most of them at once
+	pending  int64
+	maxDepth int64
+}
+
+func (stats *PoolStats) resultDone() {
+	if stats == nil {
+		return
+	}
+	depth := atomic.AddInt64(&stats.pending, 1)
+	for {
+		max := atomic.LoadInt64(&stats.maxDepth)
+		if depth <= max || atomic.CompareAndSwapInt64(&stats.maxDepth, max, depth) {
+			return
+		}
+	}
+}
+
+func (stats *PoolStats) resultCollected() {
+	if stats == nil {
+		return
+	}
+	atomic.AddInt64(&stats.pending, -1)
+}
+
+func (stats *WorkerStats) utilization() float64 {
+	total := stats.busy + stats.idle
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(stats.busy) / float64(total)
+}
+
+// Read once the pool is done, the workers are gone by then
+func printWorkerStats(stats *PoolStats) {
+	w, err := createOutputFile(workerStatsFileName)
+	if err != nil {
+		logError("%v", err)
+		return
+	}
+	writeHeader(w, "worker, files, busy, idle, utilization")
+	files := 0
+	for i, worker := range stats.workers {
+		fmt.Fprintf(w, "%d, %d, %v, %v, %.1f\n", i, worker.files, worker.busy, worker.idle, worker.utilization())
+		files += worker.files
+	}
+	w.Close()
+	fmt.Printf("Worker pool: %d workers, %d files, at most %d results waiting for the collector\n",
+		len(stats.workers), files, atomic.LoadInt64(&stats.maxDepth))
+}
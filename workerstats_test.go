@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The files of the workers sum up to the files of the run
+func TestWorkerStats(t *testing.T) {
+	dir := withOutputDir(t)
+	files := writeSpreadFiles(t, t.TempDir(), 12, 5, 50)
+	cfg := testConfig()
+	cfg.concurrency = 4
+	cfg.workerMetrics = true
+	_, result := processPackages(t, cfg, files)
+	if result.pool == nil {
+		t.Fatal("no pool stats with -workers-metrics")
+	}
+
+	if len(result.pool.workers) != cfg.concurrency {
+		t.Fatalf("%d workers, want %d", len(result.pool.workers), cfg.concurrency)
+	}
+	total := 0
+	for i, worker := range result.pool.workers {
+		total += worker.files
+		if worker.files > 0 && worker.busy <= 0 {
+			t.Errorf("worker %d: %d files in %v", i, worker.files, worker.busy)
+		}
+	}
+	if total != len(files) {
+		t.Errorf("the workers took %d files, want %d", total, len(files))
+	}
+	if result.pool.maxDepth < 1 || result.pool.maxDepth > int64(len(files)) || result.pool.pending != 0 {
+		t.Errorf("max depth %d, pending %d", result.pool.maxDepth, result.pool.pending)
+	}
+
+	printWorkerStats(result.pool)
+	rows := strings.Split(strings.TrimSpace(readOutput(t, dir, workerStatsFileName)), "\n")
+	if len(rows) != cfg.concurrency+1 || rows[0] != "worker, files, busy, idle, utilization" {
+		t.Errorf("%s:\n%s", workerStatsFileName, strings.Join(rows, "\n"))
+	}
+}